@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/johnstcn/fakeadog/pkg/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+// lintSummary counts the lint warnings of every metric received.
+type lintSummary struct {
+	mu      sync.Mutex
+	metrics int
+	warned  int
+	rules   map[parser.LintRule]int
+}
+
+// newLintSummary returns an empty lintSummary.
+func newLintSummary() *lintSummary {
+	return &lintSummary{
+		rules: make(map[parser.LintRule]int),
+	}
+}
+
+// lint logs the lint warnings of m and adds them to the summary.
+func (s *lintSummary) lint(log logrus.FieldLogger, m *parser.DatadogMetric) {
+	warnings := parser.Lint(m)
+	for _, w := range warnings {
+		fields := logrus.Fields{
+			"rule": w.Rule,
+			"name": m.Name,
+		}
+		if w.Tag != "" {
+			fields["tag"] = w.Tag
+		}
+		log.WithFields(fields).Warn(w.Message)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics++
+	if len(warnings) > 0 {
+		s.warned++
+	}
+	for _, w := range warnings {
+		s.rules[w.Rule]++
+	}
+}
+
+// log logs the number of metrics received and the number of warnings for each rule broken.
+func (s *lintSummary) log(log *logrus.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := make([]string, 0, len(s.rules))
+	for rule := range s.rules {
+		rules = append(rules, string(rule))
+	}
+	sort.Strings(rules)
+
+	log.WithFields(logrus.Fields{
+		"metrics": s.metrics,
+		"warned":  s.warned,
+	}).Info("lint summary")
+	for _, rule := range rules {
+		log.WithFields(logrus.Fields{
+			"rule":     rule,
+			"warnings": s.rules[parser.LintRule(rule)],
+		}).Info("lint summary")
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/johnstcn/fakeadog/pkg/parser"
+	"github.com/johnstcn/fakeadog/pkg/server"
 
 	"github.com/sirupsen/logrus"
 )
@@ -16,11 +22,47 @@ func main() {
 	var log = logrus.New()
 	var host string
 	var port int
+	var strict bool
+	var protocol string
+	var tagDialect string
+	var lint bool
+	var normalize bool
+	var socket string
+	var streamSocket string
+	var tcp string
+	var eolRequired bool
+	var idleTimeout time.Duration
+	var maxConns int
+	var listens listenFlag
 
+	flag.Var(&listens, "listen", "URL to listen on, e.g. udp://0.0.0.0:8125, udp6://[::1]:8125, unixgram:///tmp/dsd.sock, unix:///tmp/dsd.sock or tcp://localhost:8125, may be repeated and replaces -host and -port")
 	flag.StringVar(&host, "host", "localhost", "address to bind to, default is localhost")
 	flag.IntVar(&port, "port", 8125, "port to bind to, default is 8125")
+	flag.StringVar(&socket, "socket", "", "also listen on a unix datagram socket at this path, default is none")
+	flag.StringVar(&streamSocket, "stream-socket", "", "also listen on a unix stream socket at this path, reading length-prefixed packets, default is none")
+	flag.StringVar(&tcp, "tcp", "", "also listen on this TCP address, e.g. localhost:8125, reading newline-terminated lines, default is none")
+	flag.BoolVar(&eolRequired, "eol-required", false, "drop data left after the last newline when a TCP connection is closed")
+	flag.DurationVar(&idleTimeout, "idle-timeout", 0, "close stream connections which send nothing for this long, default is no timeout")
+	flag.IntVar(&maxConns, "max-conns", 0, "maximum number of stream connections open at once per listener, default is no limit")
+	flag.StringVar(&protocol, "protocol", string(parser.ProtocolDogStatsD), "protocol to parse, either dogstatsd or statsd, default is dogstatsd")
+	flag.StringVar(&tagDialect, "tag-dialect", "", "read tags embedded in metric names, one of auto, influx, librato, signalfx or graphite, default is none")
+	flag.BoolVar(&lint, "lint", false, "warn about metric names and tags which break the Datadog naming rules, and print a summary at exit")
+	flag.BoolVar(&normalize, "normalize", false, "also show metric names and tags normalized as Datadog would store them")
+	flag.BoolVar(&strict, "strict", false, "reject any deviation from the protocol, default is to be as lenient as the agent")
 	flag.Parse()
 
+	switch parser.Protocol(protocol) {
+	case parser.ProtocolDogStatsD, parser.ProtocolStatsD:
+	default:
+		log.Fatalf("protocol must be dogstatsd or statsd: %q", protocol)
+	}
+
+	switch parser.TagDialect(tagDialect) {
+	case parser.TagDialectNone, parser.TagDialectAuto, parser.TagDialectInflux, parser.TagDialectLibrato, parser.TagDialectSignalFx, parser.TagDialectGraphite:
+	default:
+		log.Fatalf("tag dialect must be one of auto, influx, librato, signalfx or graphite: %q", tagDialect)
+	}
+
 	if envHost := os.Getenv("HOST"); envHost != "" {
 		host = envHost
 	}
@@ -33,43 +75,239 @@ func main() {
 		port = envPortI
 	}
 
-	hostport := fmt.Sprintf("%s:%d", host, port)
-	addr, err := net.ResolveUDPAddr("udp", hostport)
+	h := &handler{
+		log:       logrus.NewEntry(log),
+		normalize: normalize,
+	}
+	if lint {
+		h.summary = newLintSummary()
+	}
+
+	var listeners []listener
+	for _, raw := range listens {
+		network, addr, err := server.ParseListenURL(raw)
+		if err != nil {
+			log.Fatalf("-listen %s: %v", raw, err)
+		}
+		listeners = append(listeners, listener{url: raw, network: network, addr: addr})
+	}
+	if len(listeners) == 0 {
+		listeners = append(listeners, newListener("udp", net.JoinHostPort(host, strconv.Itoa(port))))
+	}
+	if socket != "" {
+		listeners = append(listeners, newListener("unixgram", socket))
+	}
+	if streamSocket != "" {
+		listeners = append(listeners, newListener("unix", streamSocket))
+	}
+	if tcp != "" {
+		listeners = append(listeners, newListener("tcp", tcp))
+	}
+
+	cfg := server.Config{
+		Parser: parser.NewDatadogParserWithOptions(parser.DatadogParserOptions{
+			Protocol:   parser.Protocol(protocol),
+			TagDialect: parser.TagDialect(tagDialect),
+			Strict:     strict,
+		}),
+		IdleTimeout: idleTimeout,
+		MaxConns:    maxConns,
+		EOLRequired: eolRequired,
+	}
+	var servers []*server.Server
+	for _, l := range listeners {
+		servers = append(servers, server.New(l.config(cfg, log, h)))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := serve(ctx, log, servers)
+	if h.summary != nil {
+		h.summary.log(log)
+	}
 	if err != nil {
-		log.Fatalf("could not resolve address %s: %s\n", hostport, err)
+		log.Fatal(err)
+	}
+}
+
+// serve listens on and serves every server until ctx is done or one of them fails,
+// closing them all before returning so that no socket files are left behind.
+func serve(ctx context.Context, log *logrus.Logger, servers []*server.Server) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, srv := range servers {
+		defer srv.Close()
+		if err := srv.Listen(); err != nil {
+			return err
+		}
+		log.Infof("listening on %s %s", srv.Addr().Network(), srv.Addr())
 	}
 
-	conn, err := net.ListenUDP("udp", addr)
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *server.Server) {
+			errs <- srv.Serve(ctx)
+		}(srv)
+	}
+
+	var firstErr error
+	for range servers {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	return firstErr
+}
+
+// listenFlag collects every -listen flag.
+type listenFlag []string
+
+// String implements flag.Value.
+func (f *listenFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set implements flag.Value.
+func (f *listenFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// listener is an address fakeadog listens on.
+type listener struct {
+	// url identifies the listener in the logs.
+	url     string
+	network string
+	addr    string
+}
+
+// newListener returns a listener on addr, identified by a URL as it would be passed to -listen.
+func newListener(network, addr string) listener {
+	sep := "://"
+	if (network == "unixgram" || network == "unix") && !strings.HasPrefix(addr, "/") {
+		// relative socket paths are opaque
+		sep = ":"
+	}
+	return listener{url: network + sep + addr, network: network, addr: addr}
+}
+
+// config returns cfg listening on l, logging everything it receives with a listener field identifying l.
+func (l listener) config(cfg server.Config, log *logrus.Logger, h *handler) server.Config {
+	llog := log.WithField("listener", l.url)
+	lh := *h
+	lh.log = llog
+
+	cfg.Network = l.network
+	cfg.Addr = l.addr
+	cfg.Handler = &lh
+	cfg.OnError = func(err error) {
+		llog.Error(err)
+	}
+	cfg.OnConnClose = func(stats server.ConnStats) {
+		llog.WithFields(logrus.Fields{
+			"conn":         stats.ID,
+			"duration":     stats.Duration,
+			"frames":       stats.Frames,
+			"bytes":        stats.Bytes,
+			"metrics":      stats.Metrics,
+			"parse_errors": stats.ParseErrors,
+			"dropped":      stats.Dropped,
+			"timed_out":    stats.TimedOut,
+		}).Info("connection closed")
+	}
+	return cfg
+}
+
+// handler logs the metrics received by fakeadog.
+type handler struct {
+	log *logrus.Entry
+	// summary lints each metric if it is not nil.
+	summary *lintSummary
+	// normalize logs the normalized name and tags of each metric alongside the raw ones.
+	normalize bool
+}
+
+// Handle implements server.Handler.
+func (h *handler) Handle(m *parser.DatadogMetric, err *parser.ParseError) {
 	if err != nil {
-		log.Fatalf("could not listen on %s: %s\n", hostport, err)
+		h.log.WithFields(logrus.Fields{
+			"line":   err.Line,
+			"offset": err.Offset,
+			"hint":   err.Hint,
+			"raw":    err.Raw,
+		}).Errorf("parsing payload: %s%s", err.Err, caret(err))
+		return
+	}
+
+	fields := metricFields(m)
+	if h.normalize {
+		n := m.Normalized()
+		fields["normalized_name"] = n.Name
+		fields["normalized_tags"] = n.Tags
+	}
+	h.log.WithFields(fields).Info("received datadog metric")
+	if h.summary != nil {
+		h.summary.lint(h.log, m)
 	}
+}
 
-	log.Info("listening on ", hostport)
-	// from datadog-go/statsd
-	buf := make([]byte, 65467)
-	p := parser.NewDatadogParser()
+// caret returns the line which failed to parse with a caret pointing at the problem underneath it,
+// to be appended to the message of the entry logging err so that they cannot be separated.
+func caret(err *parser.ParseError) string {
+	return fmt.Sprintf("\n\t%s\n\t%s^", err.Raw, strings.Repeat(" ", err.Offset))
+}
 
-	defer conn.Close()
-	for {
-		n, _, err := conn.ReadFromUDP(buf)
-		if err != nil {
-			log.Error("reading from udp:", err)
-			continue
+// metricFields returns the fields of m to log.
+func metricFields(m *parser.DatadogMetric) logrus.Fields {
+	fields := logrus.Fields{
+		"type":        m.Type,
+		"name":        m.Name,
+		"value":       m.Value,
+		"tags":        m.Tags,
+		"sample_rate": m.SampleRate,
+	}
+	// packed messages carry more than one value
+	if len(m.Values) > 1 {
+		fields["values"] = m.Values
+	}
+	if m.Relative {
+		fields["relative"] = true
+	}
+	if !m.Timestamp.IsZero() {
+		fields["timestamp"] = m.Timestamp.UTC().Format(time.RFC3339)
+	}
+	if m.ContainerID != "" {
+		fields["container_id"] = m.ContainerID
+	}
+	if m.ExternalData != "" {
+		fields["external_data"] = m.ExternalData
+	}
+	if m.Cardinality != "" {
+		fields["cardinality"] = m.Cardinality
+	}
+	if sc := m.ServiceCheck; sc != nil {
+		if sc.Hostname != "" {
+			fields["hostname"] = sc.Hostname
+		}
+		if sc.Message != "" {
+			fields["message"] = sc.Message
+		}
+	}
+	if evt := m.Event; evt != nil {
+		fields["priority"] = evt.Priority
+		fields["alert_type"] = evt.AlertType
+		if evt.Hostname != "" {
+			fields["hostname"] = evt.Hostname
+		}
+		if evt.AggregationKey != "" {
+			fields["aggregation_key"] = evt.AggregationKey
 		}
-		payload := buf[:n]
-		// payload may contain multiple metrics separated by newlines
-		ms, errs := p.ParseMulti(payload)
-		for i := range errs {
-			if errs[i] != nil {
-				log.Errorf("parsing payload %q: %s", string(payload), err)
-				continue
-			}
-			log.WithFields(logrus.Fields{
-				"type":  ms[i].Type,
-				"name":  ms[i].Name,
-				"value": ms[i].Value,
-				"tags":  ms[i].Tags,
-			}).Info("received datadog metric")
+		if evt.SourceType != "" {
+			fields["source_type"] = evt.SourceType
 		}
 	}
+	return fields
 }
@@ -1,5 +1,7 @@
 module github.com/johnstcn/fakeadog
 
+go 1.20
+
 require (
 	github.com/davecgh/go-spew v1.1.0
 	github.com/pmezard/go-difflib v1.0.0
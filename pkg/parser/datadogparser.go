@@ -4,6 +4,9 @@ package parser
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"strconv"
+	"time"
 )
 
 // MetricType is stored as a string.
@@ -13,6 +16,7 @@ import (
 // - MetricHist ("H") - histogram
 // - MetricSet ("S") - set
 // - MetricTiming ("T") - timing
+// - MetricDistribution ("D") - distribution
 // - MetricServiceCheck ("_SC") - service check
 // - MetricEvent ("_E") - event
 type MetricType string
@@ -32,6 +36,8 @@ func (m MetricType) String() string {
 		return "SET"
 	case MetricTiming:
 		return "TIMING"
+	case MetricDistribution:
+		return "DISTRIBUTION"
 	case MetricServiceCheck:
 		return "SERVICE_CHECK"
 	default:
@@ -47,6 +53,20 @@ func (m MetricType) String() string {
 // - ServiceCheckUnknown ("UNKNOWN")
 type ServiceCheckStatus string
 
+// EventPriority is stored as a string.
+// Can be one of:
+// - EventPriorityNormal ("normal")
+// - EventPriorityLow ("low")
+type EventPriority string
+
+// EventAlertType is stored as a string.
+// Can be one of:
+// - EventAlertTypeError ("error")
+// - EventAlertTypeWarning ("warning")
+// - EventAlertTypeInfo ("info")
+// - EventAlertTypeSuccess ("success")
+type EventAlertType string
+
 const (
 	// MetricGauge is a Gauge metric
 	MetricGauge MetricType = "G"
@@ -58,6 +78,8 @@ const (
 	MetricSet MetricType = "S"
 	// MetricTiming is a Timing metric in ms.
 	MetricTiming MetricType = "T"
+	// MetricDistribution is a Distribution metric. Unlike a Histogram, it is aggregated server-side.
+	MetricDistribution MetricType = "D"
 	// MetricServiceCheck is a Service check. Not strictly a metric.
 	MetricServiceCheck MetricType = "_SC"
 	// MetricEvent is an Event. Again, not strictly a metric.
@@ -71,6 +93,20 @@ const (
 	ServiceCheckCritical ServiceCheckStatus = "CRITICAL"
 	// ServiceCheckUnknown is an Unknown ServiceCheckStatus.
 	ServiceCheckUnknown ServiceCheckStatus = "UNKNOWN"
+
+	// EventPriorityNormal is a normal EventPriority. This is the default.
+	EventPriorityNormal EventPriority = "normal"
+	// EventPriorityLow is a low EventPriority.
+	EventPriorityLow EventPriority = "low"
+
+	// EventAlertTypeError is an error EventAlertType.
+	EventAlertTypeError EventAlertType = "error"
+	// EventAlertTypeWarning is a warning EventAlertType.
+	EventAlertTypeWarning EventAlertType = "warning"
+	// EventAlertTypeInfo is an info EventAlertType. This is the default.
+	EventAlertTypeInfo EventAlertType = "info"
+	// EventAlertTypeSuccess is a success EventAlertType.
+	EventAlertTypeSuccess EventAlertType = "success"
 )
 
 // ErrEmptyPayload is returned upon encountering a payload containing only tags, e.g. `#foo,bar`.
@@ -82,10 +118,12 @@ var ErrInvalidTrailingPipe = fmt.Errorf("payload should have exactly one trailin
 // ErrNoTrailingPipe is returned if there is no trailing pipe before metric tags.
 var ErrNoTrailingPipe = fmt.Errorf("missing trailing pipe")
 
-// ErrNoTypeSep is returned if a metric payload has no separator between metric type and tags.
+// ErrNoTypeSep is returned if a metric has no separator between its value and type,
+// or a service check has none between its name and status.
 var ErrNoTypeSep = fmt.Errorf("missing type separator")
 
-// ErrNoValSep is returned if there is no separator between metric name and metric value.
+// ErrNoValSep is returned if there is no separator between metric name and metric value,
+// or between an event header and title.
 var ErrNoValSep = fmt.Errorf("missing value separator")
 
 // ErrInvalidMetricType is returned if an unknown metric type is encountered.
@@ -97,32 +135,262 @@ var ErrInvalidServiceCheckType = fmt.Errorf("invalid service check type")
 // ErrNoMsgSep is returned upon parsing an event with no separator between the event name and event body.
 var ErrNoMsgSep = fmt.Errorf("missing pipe between event name and body")
 
+// ErrInvalidEventHeader is returned upon parsing an event whose `{title_length,text_length}` header is malformed.
+var ErrInvalidEventHeader = fmt.Errorf("invalid event header")
+
+// ErrEventLengthMismatch is returned if the title or text of an event does not match the length declared in its header.
+var ErrEventLengthMismatch = fmt.Errorf("event title or text length does not match header")
+
+// ErrInvalidEventPriority is returned if an unknown event priority is encountered.
+var ErrInvalidEventPriority = fmt.Errorf("invalid event priority")
+
+// ErrInvalidEventAlertType is returned if an unknown event alert type is encountered.
+var ErrInvalidEventAlertType = fmt.Errorf("invalid event alert type")
+
+// ErrInvalidValue is returned if the value of a metric other than a set is not a finite number.
+var ErrInvalidValue = fmt.Errorf("invalid metric value")
+
+// ErrViewUnsupported is returned upon parsing an event or service check into a DatadogMetricView.
+var ErrViewUnsupported = fmt.Errorf("events and service checks cannot be parsed into a view")
+
+// ErrPackedValuesNotAllowed is returned if a metric type that does not allow packed values, such as a set, contains more than one value.
+var ErrPackedValuesNotAllowed = fmt.Errorf("packed values not allowed for metric type")
+
+// ErrInvalidSampleRate is returned if a sample rate is not a number in the range (0,1].
+var ErrInvalidSampleRate = fmt.Errorf("invalid sample rate")
+
+// ErrInvalidTimestamp is returned if a timestamp is not a positive number of seconds since the Unix epoch.
+var ErrInvalidTimestamp = fmt.Errorf("invalid timestamp")
+
+// ErrEmptyField is returned by a strict parser upon encountering an empty field, e.g. `foo:1||c`.
+var ErrEmptyField = fmt.Errorf("empty field")
+
+// ErrEmptyTag is returned by a strict parser upon encountering an empty tag, e.g. `#foo,,bar`.
+var ErrEmptyTag = fmt.Errorf("empty tag")
+
+// ErrDuplicateField is returned by a strict parser upon encountering an optional field more than once, e.g. `foo:1|c|#a|#b`.
+var ErrDuplicateField = fmt.Errorf("duplicate field")
+
+// ErrUnknownField is returned upon encountering an unrecognized field if unknown fields are rejected.
+var ErrUnknownField = fmt.Errorf("unknown field")
+
+// ErrNameTooLong is returned if a metric or service check name exceeds the maximum name length.
+var ErrNameTooLong = fmt.Errorf("name too long")
+
+// ErrTagTooLong is returned if a tag exceeds the maximum tag length.
+var ErrTagTooLong = fmt.Errorf("tag too long")
+
+// ErrMetricTypeNotAllowed is returned if the metric type is not one of the allowed metric types.
+var ErrMetricTypeNotAllowed = fmt.Errorf("metric type not allowed")
+
+// ErrMultipleMetrics is returned upon parsing a StatsD line containing more than one metric, e.g. `foo:1|c:2|ms`, as a single metric.
+var ErrMultipleMetrics = fmt.Errorf("line contains more than one metric")
+
+// ParseError is returned upon failing to parse a line of a payload.
+type ParseError struct {
+	// Line is the line number within the payload, starting from 1.
+	Line int
+	// Offset is the position of the problem within the line, in bytes.
+	Offset int
+	// Raw is the line which failed to parse.
+	Raw string
+	// Hint is a human-readable suggestion for fixing the problem.
+	Hint string
+	// Err is the underlying error, e.g. ErrNoValSep.
+	Err error
+}
+
+// Error implements error.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, offset %d: %s", e.Line, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error, so that errors.Is can be used to check for a specific error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseErrorHints holds the hints for each error returned within a ParseError.
+var parseErrorHints = map[error]string{
+	ErrEmptyPayload:            "payload should contain a metric, event or service check",
+	ErrInvalidTrailingPipe:     "remove the trailing pipe or add the missing field after it",
+	ErrNoTypeSep:               "add a pipe before the metric type or service check status, e.g. name:value|c or _sc|name|0",
+	ErrNoValSep:                "add a colon before the metric value or event title, e.g. name:value|c or _e{5,4}:title|text",
+	ErrInvalidMetricType:       "metric type should be one of g, c, h, s, ms or d",
+	ErrInvalidServiceCheckType: "service check status should be one of 0, 1, 2 or 3",
+	ErrNoMsgSep:                "separate the event title and text with a pipe",
+	ErrInvalidSampleRate:       "sample rate should be greater than 0 and at most 1, e.g. @0.5",
+	ErrInvalidTimestamp:        "timestamp should be a positive number of seconds since the Unix epoch",
+	ErrInvalidEventHeader:      "events should start with _e{title_length,text_length}:",
+	ErrEventLengthMismatch:     "check the title and text lengths declared in the event header",
+	ErrInvalidEventPriority:    "event priority should be normal or low",
+	ErrInvalidEventAlertType:   "event alert type should be one of error, warning, info or success",
+	ErrInvalidValue:            "values should be finite numbers, except for sets",
+	ErrPackedValuesNotAllowed:  "sets may only contain a single value",
+	ErrViewUnsupported:         "use Parse or ParseInto for events and service checks",
+	ErrEmptyField:              "remove the extra pipe",
+	ErrEmptyTag:                "remove the extra comma between tags",
+	ErrDuplicateField:          "remove the repeated field or merge it into the first, e.g. #a,b rather than #a|#b",
+	ErrUnknownField:            "remove the field or check its prefix, e.g. #tags, @sample_rate or Ttimestamp",
+	ErrNameTooLong:             "shorten the name",
+	ErrTagTooLong:              "shorten the tag",
+	ErrMetricTypeNotAllowed:    "send only the metric types the parser is configured to allow",
+	ErrMultipleMetrics:         "use ParseEach or ParseMulti to parse lines containing more than one metric",
+}
+
+// newParseError returns a ParseError for err, which occurred on the given line number while parsing line.
+func newParseError(line []byte, lineNum int, err error) *ParseError {
+	offset := 0
+	if fe, ok := err.(*fieldError); ok {
+		err = fe.err
+		// field is a subslice of line sharing its end, so their capacities differ by the offset of field
+		if o := cap(line) - cap(fe.field); o >= 0 && o <= len(line) {
+			offset = o
+		}
+	}
+	return &ParseError{
+		Line:   lineNum,
+		Offset: offset,
+		Raw:    string(line),
+		Hint:   parseErrorHints[err],
+		Err:    err,
+	}
+}
+
+// fieldError records the field of a payload which caused an error.
+type fieldError struct {
+	err   error
+	field []byte
+}
+
+// Error implements error.
+func (e *fieldError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// errorAt returns err annotated with the field of the payload which caused it.
+// Errors which are already annotated are returned unchanged.
+func errorAt(err error, field []byte) error {
+	if _, ok := err.(*fieldError); ok {
+		return err
+	}
+	return &fieldError{
+		err:   err,
+		field: field,
+	}
+}
+
 var prefixServiceCheck = []byte("_sc|")
 var prefixEvent = []byte("_e")
+var prefixEventHeader = []byte("_e{")
+var prefixSampleRate = []byte("@")
+var prefixTimestamp = []byte("T")
+var prefixEventTimestamp = []byte("d:")
+var prefixEventHostname = []byte("h:")
+var prefixEventAggregationKey = []byte("k:")
+var prefixEventPriority = []byte("p:")
+var prefixEventSourceType = []byte("s:")
+var prefixEventAlertType = []byte("t:")
+var prefixServiceCheckTimestamp = []byte("d:")
+var prefixServiceCheckHostname = []byte("h:")
+var prefixServiceCheckMessage = []byte("m:")
+var prefixContainerID = []byte("c:")
+var prefixExternalData = []byte("e:")
+var prefixCardinality = []byte("card:")
 
 var sepColon = []byte(":")
 var sepComma = []byte(",")
 var sepHash = []byte("#")
 var sepPipe = []byte("|")
 var sepNewLine = []byte("\n")
+var sepOpenBrace = []byte("{")
+var sepCloseBrace = []byte("}")
+
+var signPlus = []byte("+")
+var signMinus = []byte("-")
+
+var escapedNewLine = []byte("\\n")
+var escapedServiceCheckMessage = []byte("m\\:")
 
 var typeGauge = []byte("g")
 var typeCount = []byte("c")
 var typeHistogram = []byte("h")
 var typeSet = []byte("s")
 var typeTiming = []byte("ms")
+var typeDistribution = []byte("d")
 
 var typeServiceCheckOK = []byte("0")
 var typeServiceCheckWarn = []byte("1")
 var typeServiceCheckCritical = []byte("2")
 var typeServiceCheckUnknown = []byte("3")
 
+var typeEventPriorityNormal = []byte("normal")
+var typeEventPriorityLow = []byte("low")
+
+var typeEventAlertError = []byte("error")
+var typeEventAlertWarning = []byte("warning")
+var typeEventAlertInfo = []byte("info")
+var typeEventAlertSuccess = []byte("success")
+
 // DatadogMetric is a single DataDog metric.
 type DatadogMetric struct {
 	Name  string
 	Value string
-	Type  MetricType
-	Tags  []string
+	// Values holds every value of the metric. Metrics sent using DogStatsD protocol v1.1 may pack
+	// several values into a single message, e.g. `latency:1.2:3.4|h`, in which case Value is the first of Values.
+	Values []string
+	// FloatValue is Value parsed as a number. It is set for all metric types except sets, events and service checks.
+	FloatValue float64
+	// FloatValues holds every value of the metric parsed as a number, in the same order as Values.
+	FloatValues []float64
+	// Relative is true if the values of a gauge are signed deltas, e.g. `+5` or `-3`, to be applied to its current value.
+	Relative bool
+	Type     MetricType
+	Tags     []string
+	// SampleRate is the rate at which the metric was sampled by the client, in the range (0,1].
+	// Defaults to 1 if the payload does not specify a sample rate.
+	SampleRate float64
+	// Timestamp is the explicit time of the metric, as sent using DogStatsD protocol v1.3.
+	// It is the zero time if the payload does not specify a timestamp.
+	Timestamp time.Time
+	// ContainerID identifies the container the metric originated from, as sent using DogStatsD protocol v1.2.
+	ContainerID string
+	// ExternalData is origin detection data supplied to the client from outside the container, e.g. by an admission controller.
+	ExternalData string
+	// Cardinality is the tag cardinality requested by the client for origin detection, e.g. "low" or "high".
+	Cardinality string
+	// ServiceCheck holds the full service check if Type is MetricServiceCheck, in which case Value is the status.
+	ServiceCheck *DatadogServiceCheck
+	// Event holds the full event if Type is MetricEvent, in which case Name is the event title and Value is the event text.
+	Event *DatadogEvent
+}
+
+// DatadogServiceCheck is a single DataDog service check.
+type DatadogServiceCheck struct {
+	Name      string
+	Status    ServiceCheckStatus
+	Timestamp time.Time
+	Hostname  string
+	Message   string
+	Tags      []string
+}
+
+// DatadogEvent is a single DataDog event.
+type DatadogEvent struct {
+	Title          string
+	Text           string
+	Timestamp      time.Time
+	Hostname       string
+	AggregationKey string
+	Priority       EventPriority
+	SourceType     string
+	AlertType      EventAlertType
+	Tags           []string
 }
 
 // String returns a string representation of a Datadog metric.
@@ -131,51 +399,75 @@ func (d *DatadogMetric) String() string {
 }
 
 // DatadogParser parses datadog metrics.
+// No payload causes a DatadogParser to panic; malformed payloads are reported as a *ParseError.
 type DatadogParser interface {
 	Parse(payload []byte) (*DatadogMetric, error)
 	ParseMulti(payload []byte) ([]*DatadogMetric, []error)
+	ParseEach(payload []byte, fn func(m *DatadogMetric, err *ParseError) bool)
+	ParseInto(dst *DatadogMetric, payload []byte) error
+	ParseView(dst *DatadogMetricView, payload []byte) error
 }
 
 // datadogParser implements DatadogParser
-type datadogParser struct{}
+type datadogParser struct {
+	opts DatadogParserOptions
+}
 
 var _ DatadogParser = (*datadogParser)(nil)
 
-// NewDatadogParser returns a new instance of DatadogParser
+// NewDatadogParser returns a new instance of DatadogParser with the default, lenient options.
 func NewDatadogParser() DatadogParser {
 	return &datadogParser{}
 }
 
 // Parse parses a payload containing a single metric.
+// The payload is tokenized into pipe-separated fields, and optional fields are
+// recognized by their prefix (`#`, `@`, `T`, `c:`, ...) in any order.
+// Errors are returned as a *ParseError wrapping one of the errors above.
 func (p *datadogParser) Parse(payload []byte) (*DatadogMetric, error) {
-	var m *DatadogMetric
-	var err error
-	metricTags, tagStart := p.parseTags(payload)
+	m, err := p.parse(payload)
+	if err != nil {
+		return nil, newParseError(payload, 1, err)
+	}
+	return m, nil
+}
 
-	trimmed := payload[:tagStart]
+// ParseInto parses a payload containing a single metric into dst, overwriting all of its fields.
+// The slices and strings of dst are reused where possible, so parsing metrics into the same dst
+// repeatedly only allocates when a name, value or tag differs from the one it replaces.
+// Events and service checks are always allocated afresh.
+func (p *datadogParser) ParseInto(dst *DatadogMetric, payload []byte) error {
+	if p.isEventOrServiceCheck(payload) {
+		m, err := p.Parse(payload)
+		if err != nil {
+			return err
+		}
+		*dst = *m
+		return nil
+	}
 
-	if len(trimmed) == 0 {
-		return nil, ErrEmptyPayload
+	var v DatadogMetricView
+	if err := p.parseMetricView(&v, p.trim(payload)); err != nil {
+		return newParseError(payload, 1, err)
 	}
 
-	// trim trailing pipe if it exists
-	trimmed = bytes.TrimSuffix(trimmed, sepPipe)
+	v.copyInto(dst)
+	return nil
+}
 
-	if bytes.HasPrefix(trimmed, prefixServiceCheck) {
-		body := trimmed[len(prefixServiceCheck):]
-		m, err = p.parseServiceCheck(body, metricTags)
-	} else if bytes.HasPrefix(trimmed, prefixEvent) {
-		body := trimmed[len(prefixEvent):]
-		m, err = p.parseEvent(body, metricTags)
-	} else {
-		m, err = p.parseMetric(trimmed, metricTags)
+// ParseView parses a payload containing a single metric into dst without allocating.
+// The byte slices of dst alias payload, so dst must not be used after payload is modified.
+// Returns ErrViewUnsupported for events and service checks.
+func (p *datadogParser) ParseView(dst *DatadogMetricView, payload []byte) error {
+	if p.isEventOrServiceCheck(payload) {
+		*dst = DatadogMetricView{}
+		return newParseError(payload, 1, ErrViewUnsupported)
 	}
 
-	if err != nil {
-		return nil, err
+	if err := p.parseMetricView(dst, p.trim(payload)); err != nil {
+		return newParseError(payload, 1, err)
 	}
-
-	return m, nil
+	return nil
 }
 
 // ParseMulti parses a payload containing potentially more than one metric.
@@ -183,132 +475,541 @@ func (p *datadogParser) Parse(payload []byte) (*DatadogMetric, error) {
 func (p *datadogParser) ParseMulti(payload []byte) ([]*DatadogMetric, []error) {
 	metrics := make([]*DatadogMetric, 0)
 	errs := make([]error, 0)
-	for _, sp := range splitPayload(payload) {
+	p.ParseEach(payload, func(m *DatadogMetric, err *ParseError) bool {
+		metrics = append(metrics, m)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			// avoid appending a non-nil error interface holding a nil *ParseError
+			errs = append(errs, nil)
+		}
+		return true
+	})
+	return metrics, errs
+}
+
+// ParseEach parses a payload containing potentially more than one metric, calling fn with the result of each line in turn.
+// Exactly one of m and err is non-nil. Empty lines are skipped. Parsing stops early if fn returns false.
+func (p *datadogParser) ParseEach(payload []byte, fn func(m *DatadogMetric, err *ParseError) bool) {
+	for i, sp := range splitPayload(payload) {
 		if len(sp) == 0 {
 			continue
 		}
-		m, err := p.Parse(sp)
-		metrics = append(metrics, m)
-		errs = append(errs, err)
+		if p.opts.Protocol == ProtocolStatsD {
+			if !p.parseEachStatsD(sp, i+1, fn) {
+				return
+			}
+			continue
+		}
+		m, err := p.parse(sp)
+		var perr *ParseError
+		if err != nil {
+			perr = newParseError(sp, i+1, err)
+		}
+		if !fn(m, perr) {
+			return
+		}
 	}
-	return metrics, errs
 }
 
-// parseMetric parses a Datadog metric from trimmed, assuming tags have already been stripped.
-func (p *datadogParser) parseMetric(trimmed []byte, tags []string) (*DatadogMetric, error) {
-	// metric.name:value|type
-	if len(trimmed) < 1 {
+// isEventOrServiceCheck returns true if payload is an event or service check rather than a metric.
+func (p *datadogParser) isEventOrServiceCheck(payload []byte) bool {
+	if p.opts.Protocol == ProtocolStatsD {
+		return false
+	}
+	return bytes.HasPrefix(payload, prefixEventHeader) || bytes.HasPrefix(payload, prefixServiceCheck)
+}
+
+// parse parses a payload containing a single metric.
+// Errors are annotated with the offending field of payload where known.
+// Events and service checks are not trimmed up front, as their text and message may end in whitespace.
+func (p *datadogParser) parse(payload []byte) (*DatadogMetric, error) {
+	if p.opts.Protocol != ProtocolStatsD {
+		// metric names may begin with `_e`, so events are recognized by the brace of their header
+		if bytes.HasPrefix(payload, prefixEventHeader) {
+			return p.parseEvent(payload[len(prefixEvent):])
+		}
+
+		if bytes.HasPrefix(payload, prefixServiceCheck) {
+			return p.parseServiceCheck(payload[len(prefixServiceCheck):])
+		}
+	}
+
+	payload = p.trim(payload)
+	if len(payload) == 0 {
 		return nil, ErrEmptyPayload
 	}
+	return p.parseMetric(payload)
+}
+
+// parseMetric parses a Datadog metric from payload.
+func (p *datadogParser) parseMetric(payload []byte) (*DatadogMetric, error) {
+	var v DatadogMetricView
+	if err := p.parseMetricView(&v, payload); err != nil {
+		return nil, err
+	}
+
+	m := &DatadogMetric{}
+	v.copyInto(m)
+	return m, nil
+}
+
+// parseMetricView parses a Datadog metric from payload into v without allocating.
+// The byte slices of v alias payload.
+func (p *datadogParser) parseMetricView(v *DatadogMetricView, payload []byte) error {
+	// metric.name:value[:value...]|type[|@sample_rate][|#tags][|Ttimestamp][|c:container_id][|e:external_data][|card:cardinality]
+	if p.opts.Protocol == ProtocolStatsD {
+		return p.parseStatsDMetricView(v, payload)
+	}
+
+	*v = DatadogMetricView{
+		SampleRate: 1,
+	}
+
+	if len(payload) < 1 {
+		return errorAt(ErrEmptyPayload, payload)
+	}
+
+	rawNameAndValue, rest := nextField(payload)
+
+	// a payload consisting only of tags contains no metric
+	if bytes.HasPrefix(rawNameAndValue, sepHash) {
+		return errorAt(ErrEmptyPayload, rawNameAndValue)
+	}
 
-	// if trimmed ends with a pipe then no metric type is present
-	if bytes.HasSuffix(trimmed, sepPipe) {
-		return nil, ErrInvalidTrailingPipe
+	if rest == nil {
+		return errorAt(ErrNoTypeSep, payload[len(payload):])
+	}
+
+	// optional fields may appear anywhere after the metric name and value;
+	// exactly one of the remaining fields must be the metric type.
+	var rawMetricType []byte
+	var seen fieldKind
+	for rest != nil {
+		var field []byte
+		var kind fieldKind
+		var err error
+		field, rest = nextField(rest)
+		switch {
+		case len(field) == 0:
+			// empty fields, e.g. from a trailing pipe, are ignored unless the parser is strict
+			err = p.checkEmptyField(rest)
+		case bytes.HasPrefix(field, sepHash):
+			kind = fieldTags
+			v.RawTags = field[len(sepHash):]
+			err = p.checkTags(v.RawTags, sepComma)
+		case bytes.HasPrefix(field, prefixSampleRate):
+			kind = fieldSampleRate
+			v.SampleRate, err = p.parseSampleRate(field[len(prefixSampleRate):])
+		case bytes.HasPrefix(field, prefixTimestamp):
+			kind = fieldTimestamp
+			v.Timestamp, err = p.parseTimestamp(field[len(prefixTimestamp):])
+		case bytes.HasPrefix(field, prefixContainerID):
+			kind = fieldContainerID
+			v.ContainerID = field[len(prefixContainerID):]
+		case bytes.HasPrefix(field, prefixExternalData):
+			kind = fieldExternalData
+			v.ExternalData = field[len(prefixExternalData):]
+		case bytes.HasPrefix(field, prefixCardinality):
+			kind = fieldCardinality
+			v.Cardinality = field[len(prefixCardinality):]
+		case rawMetricType == nil:
+			rawMetricType = field
+		case p.rejectUnknownFields():
+			err = ErrUnknownField
+		}
+		if err == nil {
+			err = p.checkDuplicateField(&seen, kind)
+		}
+		if err != nil {
+			return errorAt(err, field)
+		}
 	}
 
-	typeStart := bytes.LastIndex(trimmed, sepPipe)
-	if typeStart == -1 {
-		return nil, ErrNoTypeSep
+	if rawMetricType == nil {
+		// if payload ends with a pipe then the metric type is missing rather than unseparated
+		if bytes.HasSuffix(payload, sepPipe) {
+			return errorAt(ErrInvalidTrailingPipe, payload[len(payload)-len(sepPipe):])
+		}
+		return errorAt(ErrNoTypeSep, payload[len(payload):])
 	}
 
-	rawMetricType := trimmed[typeStart+1:]
 	metricType, err := p.typeOfMetric(rawMetricType)
+	if err == nil {
+		err = p.checkType(metricType)
+	}
 	if err != nil {
-		return nil, err
+		return errorAt(err, rawMetricType)
 	}
+	v.Type = metricType
 
-	sepIdx := bytes.LastIndex(trimmed[:typeStart-1], sepColon)
+	// metric names may not contain colons, so everything after the first colon is the value
+	sepIdx := bytes.Index(rawNameAndValue, sepColon)
 	if sepIdx == -1 {
-		return nil, ErrNoValSep
+		return errorAt(ErrNoValSep, rawNameAndValue[len(rawNameAndValue):])
+	}
+
+	if err := p.parseName(v, rawNameAndValue[:sepIdx]); err != nil {
+		return err
+	}
+	v.RawValues = rawNameAndValue[sepIdx+len(sepColon):]
+	v.Value, rest = nextToken(v.RawValues, sepColon)
+	if rest != nil && metricType == MetricSet {
+		return errorAt(ErrPackedValuesNotAllowed, rest)
 	}
 
-	metricName := string(trimmed[0:sepIdx])
-	metricValue := string(trimmed[sepIdx+1 : typeStart])
+	// sets count unique occurrences of arbitrary strings, so their values are not numeric
+	if metricType != MetricSet {
+		if err := p.parseNumericValues(v); err != nil {
+			return err
+		}
+	}
 
-	return &DatadogMetric{
-		Name:  metricName,
-		Value: metricValue,
-		Type:  metricType,
-		Tags:  tags,
-	}, nil
+	return nil
 }
 
-// parseServiceCheck parses a service check from trimmed, assuming tags have already been stripped.
-func (p *datadogParser) parseServiceCheck(trimmed []byte, tags []string) (*DatadogMetric, error) {
-	// servicecheck.name|value
-	if len(trimmed) < 1 {
-		return nil, ErrEmptyPayload
+// parseNumericValues checks that the values of v are finite numbers and sets its numeric value.
+// Gauge values with an explicit sign are treated as deltas, as in StatsD.
+func (p *datadogParser) parseNumericValues(v *DatadogMetricView) error {
+	count := 0
+	signed := 0
+	for rest := v.RawValues; rest != nil; count++ {
+		var raw []byte
+		raw, rest = nextToken(rest, sepColon)
+		f, err := parseFloat(raw)
+		if err != nil {
+			return errorAt(err, raw)
+		}
+		if count == 0 {
+			v.FloatValue = f
+		}
+		if bytes.HasPrefix(raw, signPlus) || bytes.HasPrefix(raw, signMinus) {
+			signed++
+		}
 	}
 
-	// if trimmed ends with a pipe then no service check value is present
-	if bytes.HasSuffix(trimmed, sepPipe) {
-		return nil, ErrInvalidTrailingPipe
+	if v.Type == MetricGauge && signed > 0 {
+		// a packed gauge cannot mix absolute values and deltas
+		if signed != count {
+			return errorAt(ErrInvalidValue, v.RawValues)
+		}
+		v.Relative = true
 	}
 
-	typeEnd := len(trimmed)
-	typeStart := bytes.LastIndex(trimmed[:typeEnd], sepPipe)
-	if typeStart == -1 {
-		return nil, ErrNoTypeSep
+	return nil
+}
+
+// parseFloat parses a finite number.
+func parseFloat(b []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrInvalidValue
 	}
+	return f, nil
+}
 
-	rawMetricType := trimmed[typeStart+1 : typeEnd]
-	scType, err := p.typeOfServiceCheck(rawMetricType)
-	if err != nil {
+// parseServiceCheck parses a service check from payload, excluding the leading `_sc|`.
+func (p *datadogParser) parseServiceCheck(payload []byte) (*DatadogMetric, error) {
+	// name|status[|d:timestamp][|h:hostname][|#tags][|c:container_id][|e:external_data][|card:cardinality][|m:message]
+	if len(payload) < 1 {
+		return nil, errorAt(ErrEmptyPayload, payload)
+	}
+
+	if err := p.checkType(MetricServiceCheck); err != nil {
+		return nil, errorAt(err, payload)
+	}
+
+	rawName, rest := nextField(payload)
+	if rest == nil {
+		return nil, errorAt(ErrNoTypeSep, payload[len(payload):])
+	}
+	if err := p.checkName(rawName); err != nil {
 		return nil, err
 	}
 
-	scName := string(trimmed[:typeStart])
+	// if the name is followed by an empty field then no service check status is present
+	if len(rest) == 0 {
+		return nil, errorAt(ErrInvalidTrailingPipe, payload[len(payload)-len(sepPipe):])
+	}
+
+	rawStatus, rest := nextField(rest)
+	if rest == nil {
+		rawStatus = p.trim(rawStatus)
+	}
+	scStatus, err := p.typeOfServiceCheck(rawStatus)
+	if err != nil {
+		return nil, errorAt(err, rawStatus)
+	}
+
+	sc := &DatadogServiceCheck{
+		Name:   string(rawName),
+		Status: scStatus,
+	}
+
+	m := &DatadogMetric{
+		Type:         MetricServiceCheck,
+		SampleRate:   1,
+		ServiceCheck: sc,
+	}
+
+	var seen fieldKind
+	for rest != nil {
+		// the message is always the last field and may itself contain pipes
+		if bytes.HasPrefix(rest, prefixServiceCheckMessage) {
+			sc.Message = p.unescapeServiceCheckMessage(rest[len(prefixServiceCheckMessage):])
+			break
+		}
+
+		var field []byte
+		field, rest = nextField(rest)
+		// trailing whitespace is trimmed from the last field, as it is not the message
+		if rest == nil {
+			field = p.trim(field)
+		}
+		var kind fieldKind
+		switch {
+		case bytes.HasPrefix(field, prefixServiceCheckTimestamp):
+			kind = fieldTimestamp
+			sc.Timestamp, err = p.parseTimestamp(field[len(prefixServiceCheckTimestamp):])
+		case bytes.HasPrefix(field, prefixServiceCheckHostname):
+			kind = fieldHostname
+			sc.Hostname = string(field[len(prefixServiceCheckHostname):])
+		case bytes.HasPrefix(field, sepHash):
+			kind = fieldTags
+			err = p.checkTags(field[len(sepHash):], sepComma)
+			sc.Tags = p.parseTags(field[len(sepHash):])
+		case len(field) == 0:
+			err = p.checkEmptyField(rest)
+		default:
+			if kind = p.parseOriginField(field, m); kind == 0 && p.rejectUnknownFields() {
+				err = ErrUnknownField
+			}
+		}
+		if err == nil {
+			err = p.checkDuplicateField(&seen, kind)
+		}
+		if err != nil {
+			return nil, errorAt(err, field)
+		}
+	}
+
+	m.Name = sc.Name
+	m.Value = string(sc.Status)
+	m.Tags = sc.Tags
+	m.Timestamp = sc.Timestamp
 
-	return &DatadogMetric{
-		Name:  scName,
-		Value: string(scType),
-		Type:  MetricServiceCheck,
-		Tags:  tags,
-	}, nil
+	return m, nil
 }
 
-// parseEvent parses a Datadog event from trimmed, assuming tags have already been stripped.
-func (p *datadogParser) parseEvent(trimmed []byte, tags []string) (*DatadogMetric, error) {
-	// _e{name_length,message_length}:name|message
-	if len(trimmed) == 0 {
-		return nil, ErrEmptyPayload
+// unescapeServiceCheckMessage replaces escaped newlines and `m:` sequences in a service check message.
+func (p *datadogParser) unescapeServiceCheckMessage(b []byte) string {
+	b = bytes.Replace(b, escapedServiceCheckMessage, prefixServiceCheckMessage, -1)
+	return string(bytes.Replace(b, escapedNewLine, sepNewLine, -1))
+}
+
+// parseEvent parses a Datadog event from payload, excluding the leading `_e`.
+// The title and text are read strictly according to the lengths declared in the event header.
+func (p *datadogParser) parseEvent(payload []byte) (*DatadogMetric, error) {
+	// {title_length,text_length}:title|text[|d:timestamp][|h:hostname][|k:aggregation_key][|p:priority][|s:source_type][|t:alert_type][|#tags]
+	if len(payload) == 0 {
+		return nil, errorAt(ErrEmptyPayload, payload)
+	}
+
+	if err := p.checkType(MetricEvent); err != nil {
+		return nil, errorAt(err, payload)
+	}
+
+	titleLen, textLen, headerEnd, err := p.parseEventHeader(payload)
+	if err != nil {
+		return nil, errorAt(err, payload)
+	}
+
+	if !bytes.HasPrefix(payload[headerEnd:], sepColon) {
+		return nil, errorAt(ErrNoValSep, payload[headerEnd:])
+	}
+
+	body := payload[headerEnd+len(sepColon):]
+	if len(body) < titleLen {
+		return nil, errorAt(ErrEventLengthMismatch, body[len(body):])
+	}
+
+	if !bytes.HasPrefix(body[titleLen:], sepPipe) {
+		// a pipe elsewhere means the declared title length is wrong
+		if bytes.Contains(body, sepPipe) {
+			return nil, errorAt(ErrEventLengthMismatch, body[titleLen:])
+		}
+		return nil, errorAt(ErrNoMsgSep, body[titleLen:])
+	}
+
+	textStart := titleLen + len(sepPipe)
+	textEnd := textStart + textLen
+	if len(body) < textEnd {
+		return nil, errorAt(ErrEventLengthMismatch, body[len(body):])
+	}
+
+	// the text may end in whitespace, so only what follows it is trimmed
+	var rest []byte
+	if tail := p.trim(body[textEnd:]); len(tail) > 0 {
+		// anything following the text must be a separate field
+		if !bytes.HasPrefix(tail, sepPipe) {
+			return nil, errorAt(ErrEventLengthMismatch, tail)
+		}
+		rest = tail[len(sepPipe):]
 	}
 
-	nameStart := bytes.Index(trimmed, sepColon)
-	if nameStart == -1 {
-		return nil, ErrNoValSep
+	evt := &DatadogEvent{
+		Title:     p.unescapeEventText(body[:titleLen]),
+		Text:      p.unescapeEventText(body[textStart:textEnd]),
+		Priority:  EventPriorityNormal,
+		AlertType: EventAlertTypeInfo,
 	}
 
-	nameEnd := bytes.Index(trimmed, sepPipe)
-	if nameEnd == -1 {
-		return nil, ErrNoMsgSep
+	m := &DatadogMetric{
+		Type:       MetricEvent,
+		SampleRate: 1,
+		Event:      evt,
 	}
 
-	evtName := string(trimmed[nameStart+1 : nameEnd])
-	evtBody := string(trimmed[nameEnd+1:])
-	return &DatadogMetric{
-		Name:  evtName,
-		Value: evtBody,
-		Type:  MetricEvent,
-		Tags:  tags,
-	}, nil
+	var seen fieldKind
+	for rest != nil {
+		var field []byte
+		var kind fieldKind
+		field, rest = nextField(rest)
+		switch {
+		case bytes.HasPrefix(field, prefixEventTimestamp):
+			kind = fieldTimestamp
+			evt.Timestamp, err = p.parseTimestamp(field[len(prefixEventTimestamp):])
+		case bytes.HasPrefix(field, prefixEventHostname):
+			kind = fieldHostname
+			evt.Hostname = string(field[len(prefixEventHostname):])
+		case bytes.HasPrefix(field, prefixEventAggregationKey):
+			kind = fieldAggregationKey
+			evt.AggregationKey = string(field[len(prefixEventAggregationKey):])
+		case bytes.HasPrefix(field, prefixEventPriority):
+			kind = fieldPriority
+			evt.Priority, err = p.typeOfEventPriority(field[len(prefixEventPriority):])
+		case bytes.HasPrefix(field, prefixEventSourceType):
+			kind = fieldSourceType
+			evt.SourceType = string(field[len(prefixEventSourceType):])
+		case bytes.HasPrefix(field, prefixEventAlertType):
+			kind = fieldAlertType
+			evt.AlertType, err = p.typeOfEventAlertType(field[len(prefixEventAlertType):])
+		case bytes.HasPrefix(field, sepHash):
+			kind = fieldTags
+			err = p.checkTags(field[len(sepHash):], sepComma)
+			evt.Tags = p.parseTags(field[len(sepHash):])
+		case len(field) == 0:
+			err = p.checkEmptyField(rest)
+		default:
+			if kind = p.parseOriginField(field, m); kind == 0 && p.rejectUnknownFields() {
+				err = ErrUnknownField
+			}
+		}
+		if err == nil {
+			err = p.checkDuplicateField(&seen, kind)
+		}
+		if err != nil {
+			return nil, errorAt(err, field)
+		}
+	}
+
+	m.Name = evt.Title
+	m.Value = evt.Text
+	m.Tags = evt.Tags
+	m.Timestamp = evt.Timestamp
+
+	return m, nil
+}
+
+// parseEventHeader parses the `{title_length,text_length}` header at the start of payload.
+// Returns the declared lengths and the position in payload immediately after the header.
+func (p *datadogParser) parseEventHeader(payload []byte) (int, int, int, error) {
+	if !bytes.HasPrefix(payload, sepOpenBrace) {
+		return 0, 0, 0, ErrInvalidEventHeader
+	}
+
+	headerEnd := bytes.Index(payload, sepCloseBrace)
+	if headerEnd == -1 {
+		return 0, 0, 0, ErrInvalidEventHeader
+	}
+
+	lengths := bytes.Split(payload[len(sepOpenBrace):headerEnd], sepComma)
+	if len(lengths) != 2 {
+		return 0, 0, 0, ErrInvalidEventHeader
+	}
 
+	titleLen, err := strconv.Atoi(string(lengths[0]))
+	if err != nil || titleLen < 0 {
+		return 0, 0, 0, ErrInvalidEventHeader
+	}
+
+	textLen, err := strconv.Atoi(string(lengths[1]))
+	if err != nil || textLen < 0 {
+		return 0, 0, 0, ErrInvalidEventHeader
+	}
+
+	return titleLen, textLen, headerEnd + len(sepCloseBrace), nil
+}
+
+// unescapeEventText replaces escaped newlines in an event title or text.
+func (p *datadogParser) unescapeEventText(b []byte) string {
+	return string(bytes.Replace(b, escapedNewLine, sepNewLine, -1))
+}
+
+// parseOriginField parses field into m if it is a container ID, external data or cardinality field,
+// returning which of these fields it is. Returns zero if field is not one of these fields.
+func (p *datadogParser) parseOriginField(field []byte, m *DatadogMetric) fieldKind {
+	switch {
+	case bytes.HasPrefix(field, prefixContainerID):
+		m.ContainerID = string(field[len(prefixContainerID):])
+		return fieldContainerID
+	case bytes.HasPrefix(field, prefixExternalData):
+		m.ExternalData = string(field[len(prefixExternalData):])
+		return fieldExternalData
+	case bytes.HasPrefix(field, prefixCardinality):
+		m.Cardinality = string(field[len(prefixCardinality):])
+		return fieldCardinality
+	}
+	return 0
 }
 
-// parseTags returns the tags of payload and the starting position of tags in payload.
-// Tags are assumed to begin from the last index of '#' in payload.
-func (p *datadogParser) parseTags(payload []byte) ([]string, int) {
-	tags := make([]string, 0)
-	tagStart := bytes.LastIndex(payload, sepHash)
-	if tagStart == -1 {
-		return tags, len(payload)
+// parseTags splits the comma-separated tags in b, excluding the leading '#'.
+// Empty tags are dropped.
+func (p *datadogParser) parseTags(b []byte) []string {
+	if len(b) == 0 {
+		return nil
 	}
 
-	tagBytes := bytes.Split(payload[tagStart+1:], sepComma)
+	tagBytes := bytes.Split(b, sepComma)
+	tags := make([]string, 0, len(tagBytes))
 	for i := 0; i < len(tagBytes); i++ {
+		if len(tagBytes[i]) == 0 {
+			continue
+		}
 		tags = append(tags, string(tagBytes[i]))
 	}
-	return tags, tagStart
+	return tags
+}
+
+// parseSampleRate parses a sample rate, which must be in the range (0,1].
+func (p *datadogParser) parseSampleRate(b []byte) (float64, error) {
+	rate, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return 0, ErrInvalidSampleRate
+	}
+	if !(rate > 0 && rate <= 1) {
+		return 0, ErrInvalidSampleRate
+	}
+	return rate, nil
+}
+
+// parseTimestamp parses a timestamp given in seconds since the Unix epoch.
+func (p *datadogParser) parseTimestamp(b []byte) (time.Time, error) {
+	ts, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || ts < 1 {
+		return time.Time{}, ErrInvalidTimestamp
+	}
+	return time.Unix(ts, 0), nil
 }
 
 func (p *datadogParser) typeOfMetric(b []byte) (MetricType, error) {
@@ -327,6 +1028,9 @@ func (p *datadogParser) typeOfMetric(b []byte) (MetricType, error) {
 	if bytes.Equal(b, typeTiming) {
 		return MetricTiming, nil
 	}
+	if bytes.Equal(b, typeDistribution) {
+		return MetricDistribution, nil
+	}
 	return "", ErrInvalidMetricType
 }
 
@@ -350,6 +1054,54 @@ func (p *datadogParser) typeOfServiceCheck(b []byte) (ServiceCheckStatus, error)
 	return "", ErrInvalidServiceCheckType
 }
 
-func splitPayload(p []byte) ([][]byte) {
+func (p *datadogParser) typeOfEventPriority(b []byte) (EventPriority, error) {
+	if bytes.Equal(b, typeEventPriorityNormal) {
+		return EventPriorityNormal, nil
+	}
+
+	if bytes.Equal(b, typeEventPriorityLow) {
+		return EventPriorityLow, nil
+	}
+
+	return "", ErrInvalidEventPriority
+}
+
+func (p *datadogParser) typeOfEventAlertType(b []byte) (EventAlertType, error) {
+	if bytes.Equal(b, typeEventAlertError) {
+		return EventAlertTypeError, nil
+	}
+
+	if bytes.Equal(b, typeEventAlertWarning) {
+		return EventAlertTypeWarning, nil
+	}
+
+	if bytes.Equal(b, typeEventAlertInfo) {
+		return EventAlertTypeInfo, nil
+	}
+
+	if bytes.Equal(b, typeEventAlertSuccess) {
+		return EventAlertTypeSuccess, nil
+	}
+
+	return "", ErrInvalidEventAlertType
+}
+
+// nextField returns the first pipe-separated field of b and the remainder of b following the pipe.
+// The remainder is nil if there are no further fields.
+func nextField(b []byte) ([]byte, []byte) {
+	return nextToken(b, sepPipe)
+}
+
+// nextToken returns the part of b preceding the first sep and the remainder of b following it.
+// The remainder is nil if b does not contain sep.
+func nextToken(b []byte, sep []byte) ([]byte, []byte) {
+	idx := bytes.Index(b, sep)
+	if idx == -1 {
+		return b, nil
+	}
+	return b[:idx], b[idx+len(sep):]
+}
+
+func splitPayload(p []byte) [][]byte {
 	return bytes.Split(p, sepNewLine)
-}
\ No newline at end of file
+}
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"bytes"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -37,7 +40,7 @@ func (s *DatadogParserSuite) Test_Parse_Empty() {
 	input := []byte("")
 	m, err := s.p.Parse(input)
 	s.Nil(m)
-	s.EqualValues(ErrEmptyPayload, err)
+	s.True(errors.Is(err, ErrEmptyPayload))
 }
 
 func (s *DatadogParserSuite) Test_ParseMulti() {
@@ -65,6 +68,121 @@ func (s *DatadogParserSuite) Test_ParseMulti() {
 	s.Equal("2", ms[2].Value)
 }
 
+func (s *DatadogParserSuite) Test_ParseEach() {
+	input := []byte("foo:1|c|#baz,zap\n\nnotavalidmetric\nbar:2|c")
+	var ms []*DatadogMetric
+	var errs []*ParseError
+	s.p.ParseEach(input, func(m *DatadogMetric, err *ParseError) bool {
+		ms = append(ms, m)
+		errs = append(errs, err)
+		return true
+	})
+
+	s.Require().Len(ms, 3)
+	s.Require().Len(errs, 3)
+
+	s.Require().NotNil(ms[0])
+	s.Require().Nil(errs[0])
+	s.Equal("foo", ms[0].Name)
+
+	s.Require().Nil(ms[1])
+	s.Require().NotNil(errs[1])
+	s.Equal(3, errs[1].Line)
+	s.True(errors.Is(errs[1], ErrNoTypeSep))
+	s.Equal(15, errs[1].Offset)
+	s.Equal("notavalidmetric", errs[1].Raw)
+	s.NotEmpty(errs[1].Hint)
+	s.EqualError(errs[1], "line 3, offset 15: missing type separator")
+
+	s.Require().NotNil(ms[2])
+	s.Require().Nil(errs[2])
+	s.Equal("bar", ms[2].Name)
+}
+
+// parseErrorTests holds payloads which fail to parse, along with the error and offset expected.
+// They also seed the fuzz targets below.
+var parseErrorTests = []struct {
+	input  string
+	err    error
+	offset int
+}{
+	{"", ErrEmptyPayload, 0},
+	{"#foo,bar", ErrEmptyPayload, 0},
+	{"foo:1", ErrNoTypeSep, 5},
+	{"foo:1|", ErrInvalidTrailingPipe, 5},
+	{"foo|c", ErrNoValSep, 3},
+	{"|c", ErrNoValSep, 0},
+	{"|", ErrInvalidTrailingPipe, 0},
+	{"foo:1|x", ErrInvalidMetricType, 6},
+	{"foo:1|c|#env:dev|@2", ErrInvalidSampleRate, 17},
+	{"foo:1|c|Tnow", ErrInvalidTimestamp, 8},
+	{"foo:1:abc|h", ErrInvalidValue, 6},
+	{"foo:+1:2|g", ErrInvalidValue, 4},
+	{"users:a:b|s", ErrPackedValuesNotAllowed, 8},
+	{"_sc|", ErrEmptyPayload, 4},
+	{"_sc|foo", ErrNoTypeSep, 7},
+	{"_sc|foo|", ErrInvalidTrailingPipe, 7},
+	{"_sc|foo|5", ErrInvalidServiceCheckType, 8},
+	{"_sc|foo|0|d:now", ErrInvalidTimestamp, 10},
+	{"_e{", ErrInvalidEventHeader, 2},
+	{"_e{x,1}:a|b", ErrInvalidEventHeader, 2},
+	{"_e{1,1}a|b", ErrNoValSep, 7},
+	{"_e{2,1}:a|b", ErrEventLengthMismatch, 10},
+	{"_e{1,1}:ab", ErrNoMsgSep, 9},
+	{"_e{1,2}:a|b", ErrEventLengthMismatch, 11},
+	{"_e{1,1}:a|bc", ErrEventLengthMismatch, 11},
+	{"_e{1,1}:a|b|p:urgent", ErrInvalidEventPriority, 12},
+}
+
+func (s *DatadogParserSuite) Test_Parse_ParseError() {
+	for _, tc := range parseErrorTests {
+		m, err := s.p.Parse([]byte(tc.input))
+		s.Nil(m, tc.input)
+		s.Require().IsType(&ParseError{}, err, tc.input)
+		perr := err.(*ParseError)
+		s.True(errors.Is(err, tc.err), tc.input)
+		s.Equal(tc.err, perr.Err, tc.input)
+		s.Equal(1, perr.Line, tc.input)
+		s.Equal(tc.offset, perr.Offset, tc.input)
+		s.Equal(tc.input, perr.Raw, tc.input)
+		s.Equal(parseErrorHints[tc.err], perr.Hint, tc.input)
+		s.NotEmpty(perr.Hint, tc.input)
+	}
+}
+
+func (s *DatadogParserSuite) Test_ParseMulti_ParseError() {
+	input := []byte("foo:1|c\nfoo:1|x\nbar:2|c")
+	_, errs := s.p.ParseMulti(input)
+	s.Require().Len(errs, 3)
+	s.Nil(errs[0])
+	s.Nil(errs[2])
+	s.Require().IsType(&ParseError{}, errs[1])
+	perr := errs[1].(*ParseError)
+	s.Equal(2, perr.Line)
+	s.Equal(6, perr.Offset)
+	s.Equal("foo:1|x", perr.Raw)
+	s.True(errors.Is(perr, ErrInvalidMetricType))
+}
+
+func (s *DatadogParserSuite) Test_ParseEach_Stop() {
+	input := []byte("foo:1|c\nbar:2|c\nbaz:3|c")
+	var names []string
+	s.p.ParseEach(input, func(m *DatadogMetric, err *ParseError) bool {
+		names = append(names, m.Name)
+		return m.Name != "bar"
+	})
+	s.Equal([]string{"foo", "bar"}, names)
+}
+
+func (s *DatadogParserSuite) Test_ParseEach_Empty() {
+	called := false
+	s.p.ParseEach([]byte("\n\n"), func(m *DatadogMetric, err *ParseError) bool {
+		called = true
+		return true
+	})
+	s.False(called)
+}
+
 func (s *DatadogParserSuite) Test_GoStatsd_Valid_Metric() {
 	input := []byte("modprox-registry.heartbeat-accepted:1|c")
 	m, err := s.p.Parse(input)
@@ -77,25 +195,52 @@ func (s *DatadogParserSuite) Test_GoStatsd_Valid_Metric() {
 }
 
 func (s *DatadogParserSuite) Test_Parse_Metric_ValidWithTags() {
-	input := []byte("foo:bar|c|#baz,zap")
+	input := []byte("foo:1|c|#baz,zap")
 	m, err := s.p.Parse(input)
 	s.EqualValues(&DatadogMetric{
-		Name:  "foo",
-		Value: "bar",
-		Type:  MetricCount,
-		Tags:  []string{"baz", "zap"},
+		Name:        "foo",
+		Value:       "1",
+		Values:      []string{"1"},
+		FloatValue:  1,
+		FloatValues: []float64{1},
+		Type:        MetricCount,
+		Tags:        []string{"baz", "zap"},
+		SampleRate:  1,
 	}, m)
 	s.NoError(err)
 }
 
+func (s *DatadogParserSuite) Test_Parse_Metric_Distribution() {
+	input := []byte("latency:12|d|#env:dev")
+	m, err := s.p.Parse(input)
+	s.EqualValues(&DatadogMetric{
+		Name:        "latency",
+		Value:       "12",
+		Values:      []string{"12"},
+		FloatValue:  12,
+		FloatValues: []float64{12},
+		Type:        MetricDistribution,
+		Tags:        []string{"env:dev"},
+		SampleRate:  1,
+	}, m)
+	s.NoError(err)
+	s.Equal("DISTRIBUTION latency 12 [env:dev]", m.String())
+}
+
 func (s *DatadogParserSuite) Test_Parse_ServiceCheck_ValidWithTags() {
 	input := []byte("_sc|foobar|0|#baz,zap")
 	m, err := s.p.Parse(input)
 	s.EqualValues(&DatadogMetric{
-		Name:  "foobar",
-		Value: string(ServiceCheckOK),
-		Type:  MetricServiceCheck,
-		Tags:  []string{"baz", "zap"},
+		Name:       "foobar",
+		Value:      string(ServiceCheckOK),
+		Type:       MetricServiceCheck,
+		Tags:       []string{"baz", "zap"},
+		SampleRate: 1,
+		ServiceCheck: &DatadogServiceCheck{
+			Name:   "foobar",
+			Status: ServiceCheckOK,
+			Tags:   []string{"baz", "zap"},
+		},
 	}, m)
 	s.NoError(err)
 }
@@ -104,163 +249,523 @@ func (s *DatadogParserSuite) Test_Parse_Event_ValidWithTags() {
 	input := []byte("_e{3,6}:foo|barbaz|#baz,zap")
 	m, err := s.p.Parse(input)
 	s.EqualValues(&DatadogMetric{
-		Name:  "foo",
-		Value: "barbaz",
-		Type:  MetricEvent,
-		Tags:  []string{"baz", "zap"},
+		Name:       "foo",
+		Value:      "barbaz",
+		Type:       MetricEvent,
+		Tags:       []string{"baz", "zap"},
+		SampleRate: 1,
+		Event: &DatadogEvent{
+			Title:     "foo",
+			Text:      "barbaz",
+			Priority:  EventPriorityNormal,
+			AlertType: EventAlertTypeInfo,
+			Tags:      []string{"baz", "zap"},
+		},
 	}, m)
 	s.NoError(err)
 }
 
 func (s *DatadogParserSuite) Test_parseMetric_Empty() {
 	payload := []byte("")
-	tags := []string(nil)
-	m, err := s.p.parseMetric(payload, tags)
+	m, err := s.p.parseMetric(payload)
 	s.Nil(m)
-	s.EqualValues(ErrEmptyPayload, err)
+	s.True(errors.Is(err, ErrEmptyPayload))
 }
 
 func (s *DatadogParserSuite) Test_parseMetric_NoValue() {
 	payload := []byte("foobar|c")
-	tags := []string(nil)
-	m, err := s.p.parseMetric(payload, tags)
+	m, err := s.p.parseMetric(payload)
 	s.Nil(m)
-	s.EqualValues(ErrNoValSep, err)
+	s.True(errors.Is(err, ErrNoValSep))
 }
 
 func (s *DatadogParserSuite) Test_parseMetric_TrailingPipe() {
 	payload := []byte("foo:bar|")
-	tags := []string(nil)
-	m, err := s.p.parseMetric(payload, tags)
+	m, err := s.p.parseMetric(payload)
 	s.Nil(m)
-	s.EqualValues(ErrInvalidTrailingPipe, err)
+	s.True(errors.Is(err, ErrInvalidTrailingPipe))
 }
 
 func (s *DatadogParserSuite) Test_parseMetric_NoTypeSep() {
 	payload := []byte("foo:barc")
-	tags := []string(nil)
-	m, err := s.p.parseMetric(payload, tags)
+	m, err := s.p.parseMetric(payload)
 	s.Nil(m)
-	s.EqualValues(ErrNoTypeSep, err)
+	s.True(errors.Is(err, ErrNoTypeSep))
 }
 
 func (s *DatadogParserSuite) Test_parseMetric_ValidNoTags() {
-	payload := []byte("foo:bar|c")
-	tags := []string(nil)
-	m, err := s.p.parseMetric(payload, tags)
+	payload := []byte("foo:1|c")
+	m, err := s.p.parseMetric(payload)
 	s.EqualValues(&DatadogMetric{
-		Name:  "foo",
-		Value: "bar",
-		Type:  MetricCount,
-		Tags:  []string(nil),
+		Name:        "foo",
+		Value:       "1",
+		Values:      []string{"1"},
+		FloatValue:  1,
+		FloatValues: []float64{1},
+		Type:        MetricCount,
+		Tags:        []string(nil),
+		SampleRate:  1,
+	}, m)
+	s.NoError(err)
+}
+
+func (s *DatadogParserSuite) Test_parseMetric_ValidTrailingTags() {
+	payload := []byte("foo:1|c|#baz")
+	m, err := s.p.parseMetric(payload)
+	s.EqualValues(&DatadogMetric{
+		Name:        "foo",
+		Value:       "1",
+		Values:      []string{"1"},
+		FloatValue:  1,
+		FloatValues: []float64{1},
+		Type:        MetricCount,
+		Tags:        []string{"baz"},
+		SampleRate:  1,
 	}, m)
 	s.NoError(err)
 }
 
-func (s *DatadogParserSuite) Test_parseMetric_InvalidTrailingTags() {
-	payload := []byte("foo:bar|c|#baz")
-	tags := []string(nil)
-	m, err := s.p.parseMetric(payload, tags)
+func (s *DatadogParserSuite) Test_parseMetric_TwoTypes() {
+	// like the Datadog agent, the default parser ignores fields it does not recognize
+	payload := []byte("foo:1|c|g")
+	m, err := s.p.parseMetric(payload)
+	s.Require().NoError(err)
+	s.Equal(MetricCount, m.Type)
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_EventPrefix() {
+	input := []byte("_errors:1|c")
+	m, err := s.p.Parse(input)
+	s.Require().NoError(err)
+	s.Equal("_errors", m.Name)
+	s.Equal(MetricCount, m.Type)
+}
+
+func (s *DatadogParserSuite) Test_Parse_OnlyTags() {
+	input := []byte("#foo,bar")
+	m, err := s.p.Parse(input)
 	s.Nil(m)
-	s.EqualValues(ErrInvalidMetricType, err)
+	s.True(errors.Is(err, ErrEmptyPayload))
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_HashInTagValue() {
+	input := []byte("foo:1|c|#url:/a#anchor,channel:#general|@0.5")
+	m, err := s.p.Parse(input)
+	s.Require().NoError(err)
+	s.Equal("foo", m.Name)
+	s.Equal([]string{"url:/a#anchor", "channel:#general"}, m.Tags)
+	s.Equal(0.5, m.SampleRate)
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_TrailingPipe() {
+	input := []byte("foo:1|c|")
+	m, err := s.p.Parse(input)
+	s.Require().NoError(err)
+	s.Equal(MetricCount, m.Type)
+	s.Equal("1", m.Value)
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_SampleRate() {
+	input := []byte("foo:1|c|@0.5")
+	m, err := s.p.Parse(input)
+	s.Require().NoError(err)
+	s.Equal(MetricCount, m.Type)
+	s.Equal("1", m.Value)
+	s.Equal(0.5, m.SampleRate)
+	s.Empty(m.Tags)
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_SampleRateBeforeTags() {
+	input := []byte("foo:1|ms|@0.25|#baz,zap")
+	m, err := s.p.Parse(input)
+	s.Require().NoError(err)
+	s.Equal(MetricTiming, m.Type)
+	s.Equal(0.25, m.SampleRate)
+	s.Equal([]string{"baz", "zap"}, m.Tags)
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_SampleRateAfterTags() {
+	input := []byte("foo:1|c|#baz,zap|@0.1")
+	m, err := s.p.Parse(input)
+	s.Require().NoError(err)
+	s.Equal(MetricCount, m.Type)
+	s.Equal(0.1, m.SampleRate)
+	s.Equal([]string{"baz", "zap"}, m.Tags)
+}
+
+func (s *DatadogParserSuite) Test_parseMetric_InvalidSampleRate() {
+	for _, input := range []string{"foo:1|c|@0", "foo:1|c|@1.5", "foo:1|c|@-1", "foo:1|c|@", "foo:1|c|@abc", "foo:1|c|@NaN"} {
+		m, err := s.p.parseMetric([]byte(input))
+		s.Nil(m, input)
+		s.True(errors.Is(err, ErrInvalidSampleRate), input)
+	}
+}
+
+func (s *DatadogParserSuite) Test_parseMetric_OnlySampleRate() {
+	payload := []byte("foo:1|@0.5")
+	m, err := s.p.parseMetric(payload)
+	s.Nil(m)
+	s.True(errors.Is(err, ErrNoTypeSep))
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_PackedValues() {
+	input := []byte("latency:1.2:3.4:5.6|h|#env:dev")
+	m, err := s.p.Parse(input)
+	s.EqualValues(&DatadogMetric{
+		Name:        "latency",
+		Value:       "1.2",
+		Values:      []string{"1.2", "3.4", "5.6"},
+		FloatValue:  1.2,
+		FloatValues: []float64{1.2, 3.4, 5.6},
+		Type:        MetricHist,
+		Tags:        []string{"env:dev"},
+		SampleRate:  1,
+	}, m)
+	s.NoError(err)
+}
+
+func (s *DatadogParserSuite) Test_parseMetric_InvalidValue() {
+	for _, input := range []string{"foo:abc|c", "foo:|g", "foo:NaN|g", "foo:Inf|h", "foo:-Infinity|ms", "foo:1e999|d", "foo:1:abc|h", "foo:+1:2|g"} {
+		m, err := s.p.parseMetric([]byte(input))
+		s.Nil(m, input)
+		s.True(errors.Is(err, ErrInvalidValue), input)
+	}
+}
+
+func (s *DatadogParserSuite) Test_parseMetric_SetValue() {
+	payload := []byte("users:alice|s")
+	m, err := s.p.parseMetric(payload)
+	s.Require().NoError(err)
+	s.Equal("alice", m.Value)
+	s.Zero(m.FloatValue)
+	s.Nil(m.FloatValues)
+}
+
+func (s *DatadogParserSuite) Test_parseMetric_GaugeDelta() {
+	m, err := s.p.parseMetric([]byte("foo:+5|g"))
+	s.Require().NoError(err)
+	s.Equal(5.0, m.FloatValue)
+	s.True(m.Relative)
+
+	m, err = s.p.parseMetric([]byte("foo:-3|g"))
+	s.Require().NoError(err)
+	s.Equal(-3.0, m.FloatValue)
+	s.True(m.Relative)
+
+	m, err = s.p.parseMetric([]byte("foo:3|g"))
+	s.Require().NoError(err)
+	s.Equal(3.0, m.FloatValue)
+	s.False(m.Relative)
+
+	// only gauges may be relative
+	m, err = s.p.parseMetric([]byte("foo:-3|c"))
+	s.Require().NoError(err)
+	s.Equal(-3.0, m.FloatValue)
+	s.False(m.Relative)
+}
+
+func (s *DatadogParserSuite) Test_parseMetric_PackedSet() {
+	payload := []byte("users:alice:bob|s")
+	m, err := s.p.parseMetric(payload)
+	s.Nil(m)
+	s.True(errors.Is(err, ErrPackedValuesNotAllowed))
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_Timestamp() {
+	for _, input := range []string{
+		"foo:1|g|T1656581409",
+		"foo:1|g|#env:dev|T1656581409|@0.5",
+		"foo:1|g|@0.5|T1656581409|#env:dev",
+		"foo:1|g|T1656581409|#env:dev|@0.5",
+	} {
+		m, err := s.p.Parse([]byte(input))
+		s.Require().NoError(err, input)
+		s.Equal(MetricGauge, m.Type, input)
+		s.Equal("1", m.Value, input)
+		s.True(time.Unix(1656581409, 0).Equal(m.Timestamp), input)
+	}
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_NoTimestamp() {
+	m, err := s.p.Parse([]byte("foo:1|g"))
+	s.Require().NoError(err)
+	s.True(m.Timestamp.IsZero())
+}
+
+func (s *DatadogParserSuite) Test_parseMetric_InvalidTimestamp() {
+	for _, input := range []string{"foo:1|g|T", "foo:1|g|T0", "foo:1|g|T-5", "foo:1|g|Tnow", "foo:1|g|T1.5"} {
+		m, err := s.p.parseMetric([]byte(input))
+		s.Nil(m, input)
+		s.True(errors.Is(err, ErrInvalidTimestamp), input)
+	}
+}
+
+func (s *DatadogParserSuite) Test_Parse_Metric_OriginFields() {
+	input := []byte("foo:1|c|c:abc123|#env:dev|e:it-false,cn-app,pu-f00|card:high")
+	m, err := s.p.Parse(input)
+	s.EqualValues(&DatadogMetric{
+		Name:         "foo",
+		Value:        "1",
+		Values:       []string{"1"},
+		FloatValue:   1,
+		FloatValues:  []float64{1},
+		Type:         MetricCount,
+		Tags:         []string{"env:dev"},
+		SampleRate:   1,
+		ContainerID:  "abc123",
+		ExternalData: "it-false,cn-app,pu-f00",
+		Cardinality:  "high",
+	}, m)
+	s.NoError(err)
+}
+
+func (s *DatadogParserSuite) Test_Parse_ServiceCheck_OriginFields() {
+	input := []byte("_sc|foobar|1|#baz|c:abc123|card:low")
+	m, err := s.p.Parse(input)
+	s.EqualValues(&DatadogMetric{
+		Name:        "foobar",
+		Value:       string(ServiceCheckWarn),
+		Type:        MetricServiceCheck,
+		Tags:        []string{"baz"},
+		SampleRate:  1,
+		ContainerID: "abc123",
+		Cardinality: "low",
+		ServiceCheck: &DatadogServiceCheck{
+			Name:   "foobar",
+			Status: ServiceCheckWarn,
+			Tags:   []string{"baz"},
+		},
+	}, m)
+	s.NoError(err)
+}
+
+func (s *DatadogParserSuite) Test_Parse_Event_OriginFields() {
+	input := []byte("_e{3,6}:foo|barbaz|c:abc123|e:it-true|#baz")
+	m, err := s.p.Parse(input)
+	s.EqualValues(&DatadogMetric{
+		Name:         "foo",
+		Value:        "barbaz",
+		Type:         MetricEvent,
+		Tags:         []string{"baz"},
+		SampleRate:   1,
+		ContainerID:  "abc123",
+		ExternalData: "it-true",
+		Event: &DatadogEvent{
+			Title:     "foo",
+			Text:      "barbaz",
+			Priority:  EventPriorityNormal,
+			AlertType: EventAlertTypeInfo,
+			Tags:      []string{"baz"},
+		},
+	}, m)
+	s.NoError(err)
 }
 
 func (s *DatadogParserSuite) Test_parseServiceCheck_Empty() {
 	payload := []byte("")
-	tags := []string(nil)
-	m, err := s.p.parseServiceCheck(payload, tags)
+	m, err := s.p.parseServiceCheck(payload)
 	s.Nil(m)
-	s.EqualValues(ErrEmptyPayload, err)
+	s.True(errors.Is(err, ErrEmptyPayload))
 }
 
 func (s *DatadogParserSuite) Test_parseServiceCheck_TrailingPipe() {
 	payload := []byte("foo.bar|")
-	tags := []string(nil)
-	m, err := s.p.parseServiceCheck(payload, tags)
+	m, err := s.p.parseServiceCheck(payload)
 	s.Nil(m)
-	s.EqualValues(ErrInvalidTrailingPipe, err)
+	s.True(errors.Is(err, ErrInvalidTrailingPipe))
 }
 
 func (s *DatadogParserSuite) Test_parseServiceCheck_NoType() {
 	payload := []byte("foo.bar")
-	tags := []string(nil)
-	m, err := s.p.parseServiceCheck(payload, tags)
+	m, err := s.p.parseServiceCheck(payload)
 	s.Nil(m)
-	s.EqualValues(ErrNoTypeSep, err)
+	s.True(errors.Is(err, ErrNoTypeSep))
 }
 
 func (s *DatadogParserSuite) Test_parseServiceCheck_InvalidType() {
 	payload := []byte("foo.bar|baz")
-	tags := []string(nil)
-	m, err := s.p.parseServiceCheck(payload, tags)
+	m, err := s.p.parseServiceCheck(payload)
 	s.Nil(m)
-	s.EqualValues(ErrInvalidServiceCheckType, err)
+	s.True(errors.Is(err, ErrInvalidServiceCheckType))
+}
+
+func (s *DatadogParserSuite) Test_Parse_ServiceCheck_AllFields() {
+	input := []byte("_sc|db.up|2|d:1700000000|h:db1|#env:prod,team:a#b|m:connection refused|retrying #3\\nm\\: gave up")
+	m, err := s.p.Parse(input)
+	s.Require().NoError(err)
+	s.Equal(MetricServiceCheck, m.Type)
+	s.Equal("db.up", m.Name)
+	s.Equal(string(ServiceCheckCritical), m.Value)
+	s.Equal([]string{"env:prod", "team:a#b"}, m.Tags)
+	s.EqualValues(&DatadogServiceCheck{
+		Name:      "db.up",
+		Status:    ServiceCheckCritical,
+		Timestamp: time.Unix(1700000000, 0),
+		Hostname:  "db1",
+		Message:   "connection refused|retrying #3\nm: gave up",
+		Tags:      []string{"env:prod", "team:a#b"},
+	}, m.ServiceCheck)
+	s.True(m.ServiceCheck.Timestamp.Equal(m.Timestamp))
+}
+
+func (s *DatadogParserSuite) Test_Parse_ServiceCheck_TrailingPipe() {
+	m, err := s.p.Parse([]byte("_sc|foobar|0|"))
+	s.Require().NoError(err)
+	s.Equal("foobar", m.Name)
+	s.Equal(string(ServiceCheckOK), m.Value)
+}
+
+func (s *DatadogParserSuite) Test_parseServiceCheck_InvalidTimestamp() {
+	m, err := s.p.parseServiceCheck([]byte("foo.bar|0|d:yesterday"))
+	s.Nil(m)
+	s.True(errors.Is(err, ErrInvalidTimestamp))
 }
 
 func (s *DatadogParserSuite) Test_parseEvent_Empty() {
 	input := []byte("")
-	e, err := s.p.parseEvent(input, []string(nil))
+	e, err := s.p.parseEvent(input)
 	s.Nil(e)
-	s.EqualValues(ErrEmptyPayload, err)
+	s.True(errors.Is(err, ErrEmptyPayload))
 }
 
 func (s *DatadogParserSuite) Test_parseEvent_MissingValSep() {
 	input := []byte("{3,6}foo|barbaz")
-	e, err := s.p.parseEvent(input, []string(nil))
+	e, err := s.p.parseEvent(input)
 	s.Nil(e)
-	s.EqualValues(ErrNoValSep, err)
+	s.True(errors.Is(err, ErrNoValSep))
 }
 
 func (s *DatadogParserSuite) Test_parseEvent_MissingMsgSep() {
 	input := []byte("{3,6}:foobarbaz")
-	e, err := s.p.parseEvent(input, []string(nil))
+	e, err := s.p.parseEvent(input)
 	s.Nil(e)
-	s.EqualValues(ErrNoMsgSep, err)
+	s.True(errors.Is(err, ErrNoMsgSep))
 }
 
 func (s *DatadogParserSuite) Test_parseEvent_ValidNoTags() {
 	input := []byte("{3,6}:foo|barbaz")
-	e, err := s.p.parseEvent(input, []string(nil))
+	e, err := s.p.parseEvent(input)
 	s.EqualValues(&DatadogMetric{
-		Name:  "foo",
-		Value: "barbaz",
-		Type:  MetricEvent,
-		Tags:  []string(nil),
+		Name:       "foo",
+		Value:      "barbaz",
+		Type:       MetricEvent,
+		Tags:       []string(nil),
+		SampleRate: 1,
+		Event: &DatadogEvent{
+			Title:     "foo",
+			Text:      "barbaz",
+			Priority:  EventPriorityNormal,
+			AlertType: EventAlertTypeInfo,
+		},
 	}, e)
 	s.NoError(err)
 }
 
 func (s *DatadogParserSuite) Test_parseEvent_ValidEmpty() {
 	input := []byte("{0,0}:|")
-	e, err := s.p.parseEvent(input, []string(nil))
+	e, err := s.p.parseEvent(input)
 	s.EqualValues(&DatadogMetric{
-		Name:  "",
-		Value: "",
-		Type:  MetricEvent,
-		Tags:  []string(nil),
+		Name:       "",
+		Value:      "",
+		Type:       MetricEvent,
+		Tags:       []string(nil),
+		SampleRate: 1,
+		Event: &DatadogEvent{
+			Priority:  EventPriorityNormal,
+			AlertType: EventAlertTypeInfo,
+		},
 	}, e)
 	s.NoError(err)
 }
 
+func (s *DatadogParserSuite) Test_Parse_Event_AllFields() {
+	input := []byte("_e{9,14}:deploy|#1|v1.2 done\\n|ok|d:1700000000|h:web1|k:deploys|p:low|s:jenkins|t:success|#env:prod,team:a#b|c:abc123")
+	m, err := s.p.Parse(input)
+	s.Require().NoError(err)
+	s.Equal(MetricEvent, m.Type)
+	s.Equal("deploy|#1", m.Name)
+	s.Equal("v1.2 done\n|ok", m.Value)
+	s.Equal([]string{"env:prod", "team:a#b"}, m.Tags)
+	s.Equal("abc123", m.ContainerID)
+	s.EqualValues(&DatadogEvent{
+		Title:          "deploy|#1",
+		Text:           "v1.2 done\n|ok",
+		Timestamp:      time.Unix(1700000000, 0),
+		Hostname:       "web1",
+		AggregationKey: "deploys",
+		Priority:       EventPriorityLow,
+		SourceType:     "jenkins",
+		AlertType:      EventAlertTypeSuccess,
+		Tags:           []string{"env:prod", "team:a#b"},
+	}, m.Event)
+	s.True(m.Event.Timestamp.Equal(m.Timestamp))
+}
+
+func (s *DatadogParserSuite) Test_parseEvent_InvalidHeader() {
+	for _, input := range []string{"3,6}:foo|barbaz", "{3,6:foo|barbaz", "{3}:foo|barbaz", "{a,6}:foo|barbaz", "{3,-6}:foo|barbaz", "{3,6,9}:foo|barbaz"} {
+		e, err := s.p.parseEvent([]byte(input))
+		s.Nil(e, input)
+		s.True(errors.Is(err, ErrInvalidEventHeader), input)
+	}
+}
+
+func (s *DatadogParserSuite) Test_parseEvent_LengthMismatch() {
+	for _, input := range []string{"{4,6}:foo", "{3,7}:foo|barbaz", "{3,5}:foo|barbaz", "{3,6}:foo|barbaz#tag"} {
+		e, err := s.p.parseEvent([]byte(input))
+		s.Nil(e, input)
+		s.True(errors.Is(err, ErrEventLengthMismatch), input)
+	}
+}
+
+func (s *DatadogParserSuite) Test_parseEvent_InvalidPriority() {
+	e, err := s.p.parseEvent([]byte("{3,6}:foo|barbaz|p:urgent"))
+	s.Nil(e)
+	s.True(errors.Is(err, ErrInvalidEventPriority))
+}
+
+func (s *DatadogParserSuite) Test_parseEvent_InvalidAlertType() {
+	e, err := s.p.parseEvent([]byte("{3,6}:foo|barbaz|t:fatal"))
+	s.Nil(e)
+	s.True(errors.Is(err, ErrInvalidEventAlertType))
+}
+
+func (s *DatadogParserSuite) Test_parseEvent_InvalidTimestamp() {
+	e, err := s.p.parseEvent([]byte("{3,6}:foo|barbaz|d:yesterday"))
+	s.Nil(e)
+	s.True(errors.Is(err, ErrInvalidTimestamp))
+}
+
 func (s *DatadogParserSuite) Test_parseTags_Empty() {
 	input := []byte("")
-	tags, idx := s.p.parseTags(input)
+	tags := s.p.parseTags(input)
 	s.Empty(tags)
-	s.EqualValues(0, idx)
 }
 
 func (s *DatadogParserSuite) Test_parseTags_ValidOneTag() {
-	input := []byte("k:v|c|#foo:1")
-	tags, idx := s.p.parseTags(input)
+	input := []byte("foo:1")
+	tags := s.p.parseTags(input)
 	s.EqualValues([]string{"foo:1"}, tags)
-	s.EqualValues(6, idx)
 }
 
 func (s *DatadogParserSuite) Test_parseTags_ValidTwoTags() {
-	input := []byte("k:v|c|#foo:1,bar:2")
-	tags, idx := s.p.parseTags(input)
+	input := []byte("foo:1,bar:2")
+	tags := s.p.parseTags(input)
 	s.EqualValues([]string{"foo:1", "bar:2"}, tags)
-	s.EqualValues(6, idx)
+}
+
+func (s *DatadogParserSuite) Test_nextField() {
+	field, rest := nextField([]byte("k:v|c|#foo:1"))
+	s.EqualValues("k:v", field)
+	s.EqualValues("c|#foo:1", rest)
+
+	field, rest = nextField([]byte("#foo:1"))
+	s.EqualValues("#foo:1", field)
+	s.Nil(rest)
+
+	field, rest = nextField([]byte("c|"))
+	s.EqualValues("c", field)
+	s.NotNil(rest)
+	s.Empty(rest)
 }
 
 func (s *DatadogParserSuite) Test_typeOfMetric() {
@@ -288,6 +793,10 @@ func (s *DatadogParserSuite) Test_typeOfMetric() {
 	s.EqualValues(MetricTiming, m)
 	s.NoError(err)
 
+	m, err = s.p.typeOfMetric(typeDistribution)
+	s.EqualValues(MetricDistribution, m)
+	s.NoError(err)
+
 	m, err = s.p.typeOfMetric([]byte(""))
 	s.Empty(m)
 	s.EqualValues(err, ErrInvalidMetricType)
@@ -324,7 +833,290 @@ func (s *DatadogParserSuite) Test_typeOfServiceCheck() {
 	s.EqualValues(ErrInvalidServiceCheckType, err)
 }
 
+func (s *DatadogParserSuite) Test_typeOfEventPriority() {
+	t, err := s.p.typeOfEventPriority(typeEventPriorityNormal)
+	s.EqualValues(EventPriorityNormal, t)
+	s.NoError(err)
+
+	t, err = s.p.typeOfEventPriority(typeEventPriorityLow)
+	s.EqualValues(EventPriorityLow, t)
+	s.NoError(err)
+
+	t, err = s.p.typeOfEventPriority([]byte(""))
+	s.Zero(t)
+	s.EqualValues(ErrInvalidEventPriority, err)
+}
+
+func (s *DatadogParserSuite) Test_typeOfEventAlertType() {
+	t, err := s.p.typeOfEventAlertType(typeEventAlertError)
+	s.EqualValues(EventAlertTypeError, t)
+	s.NoError(err)
+
+	t, err = s.p.typeOfEventAlertType(typeEventAlertWarning)
+	s.EqualValues(EventAlertTypeWarning, t)
+	s.NoError(err)
+
+	t, err = s.p.typeOfEventAlertType(typeEventAlertInfo)
+	s.EqualValues(EventAlertTypeInfo, t)
+	s.NoError(err)
+
+	t, err = s.p.typeOfEventAlertType(typeEventAlertSuccess)
+	s.EqualValues(EventAlertTypeSuccess, t)
+	s.NoError(err)
+
+	t, err = s.p.typeOfEventAlertType([]byte("foo"))
+	s.Zero(t)
+	s.EqualValues(ErrInvalidEventAlertType, err)
+}
+
+func (s *DatadogParserSuite) Test_ParseInto() {
+	input := []byte("latency:1.2:3.4|h|@0.5|#env:dev,team:a|T1656581409|c:abc123")
+	expected, err := s.p.Parse(input)
+	s.Require().NoError(err)
+
+	var m DatadogMetric
+	s.Require().NoError(s.p.ParseInto(&m, input))
+	s.EqualValues(expected, &m)
+
+	// parsing into the same metric overwrites every field
+	s.Require().NoError(s.p.ParseInto(&m, []byte("users:alice|s")))
+	s.EqualValues(&DatadogMetric{
+		Name:        "users",
+		Value:       "alice",
+		Values:      []string{"alice"},
+		FloatValues: []float64{},
+		Type:        MetricSet,
+		Tags:        []string{},
+		SampleRate:  1,
+	}, &m)
+
+	s.Require().NoError(s.p.ParseInto(&m, []byte("_sc|foobar|0|#baz")))
+	s.Equal(MetricServiceCheck, m.Type)
+	s.Equal("foobar", m.Name)
+	s.Nil(m.Values)
+	s.NotNil(m.ServiceCheck)
+
+	s.Require().NoError(s.p.ParseInto(&m, input))
+	s.EqualValues(expected, &m)
+}
+
+func (s *DatadogParserSuite) Test_ParseInto_Invalid() {
+	var m DatadogMetric
+	s.True(errors.Is(s.p.ParseInto(&m, []byte("")), ErrEmptyPayload))
+	s.True(errors.Is(s.p.ParseInto(&m, []byte("foo:1|x")), ErrInvalidMetricType))
+	s.True(errors.Is(s.p.ParseInto(&m, []byte("_e{2,6}:foo|barbaz")), ErrEventLengthMismatch))
+}
+
+func (s *DatadogParserSuite) Test_ParseInto_ReusesStrings() {
+	input := []byte("foo:1|c|#env:dev,team:a")
+	var m DatadogMetric
+	s.Require().NoError(s.p.ParseInto(&m, input))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = s.p.ParseInto(&m, input)
+	})
+	s.Zero(allocs)
+}
+
+func (s *DatadogParserSuite) Test_ParseView() {
+	input := []byte("latency:1.2:3.4|h|@0.5|#env:dev,team:a|T1656581409|c:abc123|e:it-true|card:low")
+	var v DatadogMetricView
+	s.Require().NoError(s.p.ParseView(&v, input))
+	s.EqualValues("latency", v.Name)
+	s.EqualValues("1.2", v.Value)
+	s.EqualValues("1.2:3.4", v.RawValues)
+	s.Equal(1.2, v.FloatValue)
+	s.False(v.Relative)
+	s.Equal(MetricHist, v.Type)
+	s.EqualValues("env:dev,team:a", v.RawTags)
+	s.Equal(0.5, v.SampleRate)
+	s.True(time.Unix(1656581409, 0).Equal(v.Timestamp))
+	s.EqualValues("abc123", v.ContainerID)
+	s.EqualValues("it-true", v.ExternalData)
+	s.EqualValues("low", v.Cardinality)
+}
+
+func (s *DatadogParserSuite) Test_ParseView_ZeroAllocs() {
+	inputs := [][]byte{
+		[]byte("foo:1|c"),
+		[]byte("foo:1|c|#env:dev,team:a|@0.5"),
+		[]byte("latency:1.2:3.4:5.6|h|#env:dev"),
+		[]byte("temperature:-3|g|T1656581409|c:abc123"),
+		[]byte("users:alice|s|#env:dev"),
+	}
+	var v DatadogMetricView
+	for _, input := range inputs {
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = s.p.ParseView(&v, input)
+		})
+		s.Zero(allocs, string(input))
+	}
+}
+
+func (s *DatadogParserSuite) Test_ParseView_Unsupported() {
+	var v DatadogMetricView
+	s.True(errors.Is(s.p.ParseView(&v, []byte("_e{3,6}:foo|barbaz")), ErrViewUnsupported))
+	s.True(errors.Is(s.p.ParseView(&v, []byte("_sc|foobar|0")), ErrViewUnsupported))
+}
+
+func (s *DatadogParserSuite) Test_ParseView_Invalid() {
+	var v DatadogMetricView
+	s.True(errors.Is(s.p.ParseView(&v, []byte("foobar|c")), ErrNoValSep))
+	s.True(errors.Is(s.p.ParseView(&v, []byte("foo:abc|c")), ErrInvalidValue))
+}
+
 func TestDatadogParserSuite(t *testing.T) {
 	suite.Run(t, new(DatadogMetricSuite))
 	suite.Run(t, new(DatadogParserSuite))
 }
+
+var benchmarkPayloads = []struct {
+	name    string
+	payload []byte
+}{
+	{"Count", []byte("foo.bar.count:1|c")},
+	{"Tagged", []byte("foo.bar.count:1|c|@0.5|#env:dev,app:myapp,hostname:myhost")},
+	{"Packed", []byte("foo.bar.latency:1.2:3.4:5.6:7.8|h|#env:dev,app:myapp")},
+	{"Set", []byte("foo.bar.users:alice|s|#env:dev")},
+	{"Extended", []byte("foo.bar.gauge:42|g|#env:dev|T1656581409|c:abc123|card:low")},
+}
+
+func BenchmarkParse(b *testing.B) {
+	p := NewDatadogParser()
+	for _, bp := range benchmarkPayloads {
+		payload := bp.payload
+		b.Run(bp.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = p.Parse(payload)
+			}
+		})
+	}
+}
+
+func BenchmarkParseInto(b *testing.B) {
+	p := NewDatadogParser()
+	for _, bp := range benchmarkPayloads {
+		payload := bp.payload
+		b.Run(bp.name, func(b *testing.B) {
+			var m DatadogMetric
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = p.ParseInto(&m, payload)
+			}
+		})
+	}
+}
+
+func BenchmarkParseView(b *testing.B) {
+	p := NewDatadogParser()
+	for _, bp := range benchmarkPayloads {
+		payload := bp.payload
+		b.Run(bp.name, func(b *testing.B) {
+			var v DatadogMetricView
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = p.ParseView(&v, payload)
+			}
+		})
+	}
+}
+
+func BenchmarkParseMulti(b *testing.B) {
+	p := NewDatadogParser()
+	lines := make([][]byte, 0, len(benchmarkPayloads))
+	for _, bp := range benchmarkPayloads {
+		lines = append(lines, bp.payload)
+	}
+	payload := bytes.Join(lines, []byte("\n"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = p.ParseMulti(payload)
+	}
+}
+
+// fuzzSeeds returns the payloads used to seed the fuzz targets.
+func fuzzSeeds() [][]byte {
+	seeds := [][]byte{
+		[]byte(":|"),
+		[]byte("_e{1,1}:a|b|d:1656581409|h:host|k:key|p:low|s:src|t:error|#env:dev|c:abc"),
+		[]byte("_sc|db.up|2|d:1700000000|h:db1|#env:prod|m:connection\\nrefused"),
+	}
+	for _, tc := range parseErrorTests {
+		seeds = append(seeds, []byte(tc.input))
+	}
+	for _, bp := range benchmarkPayloads {
+		seeds = append(seeds, bp.payload)
+	}
+	return seeds
+}
+
+// fuzzParsers returns parsers for each mode and protocol, so that all of them are fuzzed.
+func fuzzParsers() []DatadogParser {
+	return []DatadogParser{
+		NewDatadogParser(),
+		NewDatadogParserWithOptions(DatadogParserOptions{Strict: true, MaxNameLength: 8, MaxTagLength: 8}),
+		NewDatadogParserWithOptions(DatadogParserOptions{Protocol: ProtocolStatsD, TagDialect: TagDialectSignalFx}),
+		NewDatadogParserWithOptions(DatadogParserOptions{TagDialect: TagDialectAuto, Strict: true, MaxTagLength: 8}),
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	parsers := fuzzParsers()
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		for _, p := range parsers {
+			m, err := p.Parse(payload)
+			if (m == nil) == (err == nil) {
+				t.Fatalf("Parse(%q) returned metric %v and error %v", payload, m, err)
+			}
+			if err != nil {
+				perr, ok := err.(*ParseError)
+				if !ok {
+					t.Fatalf("Parse(%q) returned %T, expected *ParseError", payload, err)
+				}
+				if perr.Offset < 0 || perr.Offset > len(perr.Raw) {
+					t.Fatalf("Parse(%q) returned offset %d outside of line", payload, perr.Offset)
+				}
+			} else if _, err := Marshal(m); err != nil {
+				t.Fatalf("Marshal of Parse(%q) failed: %s", payload, err)
+			}
+
+			var dst DatadogMetric
+			if intoErr := p.ParseInto(&dst, payload); (intoErr == nil) != (err == nil) {
+				t.Fatalf("ParseInto(%q) returned %v, Parse returned %v", payload, intoErr, err)
+			}
+
+			var v DatadogMetricView
+			_ = p.ParseView(&v, payload)
+			_ = v.AppendValues(nil)
+			_ = v.AppendFloatValues(nil)
+			_ = v.AppendTags(nil)
+		}
+	})
+}
+
+func FuzzParseMulti(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Add([]byte("foo:1|c\n\n|c\r\n_sc|x|0\nbar:2|g"))
+	f.Add([]byte("foo:1|c:2|ms|@0.5:x|c\nbar:+1|g"))
+	f.Add([]byte("foo,a=b:1|c\nfoo#a=b,c:1|c\n[a=b]foo:1|c\nfoo;a=b;c=d:1|c"))
+	parsers := fuzzParsers()
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		for _, p := range parsers {
+			ms, errs := p.ParseMulti(payload)
+			if len(ms) != len(errs) {
+				t.Fatalf("ParseMulti(%q) returned %d metrics and %d errors", payload, len(ms), len(errs))
+			}
+			for i := range ms {
+				if (ms[i] == nil) == (errs[i] == nil) {
+					t.Fatalf("ParseMulti(%q) returned metric %v and error %v at %d", payload, ms[i], errs[i], i)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"bytes"
+)
+
+// TagDialect is a convention for embedding tags in a metric name rather than sending them as DogStatsD tags.
+// Can be one of:
+// - TagDialectNone ("") - tags are not read from metric names, the default
+// - TagDialectAuto ("auto") - the dialect is detected from each metric name
+// - TagDialectInflux ("influx") - InfluxDB, e.g. `name,tag=val`
+// - TagDialectLibrato ("librato") - Librato, e.g. `name#tag=val`
+// - TagDialectSignalFx ("signalfx") - SignalFx, e.g. `name[tag=val]` or `[tag=val]name`
+// - TagDialectGraphite ("graphite") - Graphite, e.g. `name;tag=val`
+type TagDialect string
+
+const (
+	// TagDialectNone does not read tags from metric names.
+	TagDialectNone TagDialect = ""
+	// TagDialectAuto detects the dialect of each metric name from the separators it contains.
+	TagDialectAuto TagDialect = "auto"
+	// TagDialectInflux reads comma-separated tags following the first comma of a name, e.g. `name,tag1=val1,tag2=val2`.
+	TagDialectInflux TagDialect = "influx"
+	// TagDialectLibrato reads comma-separated tags following the first hash of a name, e.g. `name#tag1=val1,tag2=val2`.
+	TagDialectLibrato TagDialect = "librato"
+	// TagDialectSignalFx reads comma-separated tags in brackets at the start or end of a name, e.g. `name[tag1=val1,tag2=val2]`.
+	TagDialectSignalFx TagDialect = "signalfx"
+	// TagDialectGraphite reads semicolon-separated tags following the first semicolon of a name, e.g. `name;tag1=val1;tag2=val2`.
+	TagDialectGraphite TagDialect = "graphite"
+)
+
+var sepSemicolon = []byte(";")
+var sepEquals = []byte("=")
+var sepOpenBracket = []byte("[")
+var sepCloseBracket = []byte("]")
+
+// parseName sets the name of v to rawName, moving any tags embedded in rawName to the name tags of v.
+func (p *datadogParser) parseName(v *DatadogMetricView, rawName []byte) error {
+	v.Name = rawName
+	if p.opts.TagDialect != TagDialectNone {
+		v.Name, v.NameTags, v.nameTagSep = p.splitNameTags(rawName)
+	}
+
+	if err := p.checkName(v.Name); err != nil {
+		return err
+	}
+	if v.nameTagSep != nil {
+		return p.checkTags(v.NameTags, v.nameTagSep)
+	}
+	return nil
+}
+
+// splitNameTags splits rawName into the metric name and the tags embedded in it according to the tag dialect.
+// Returns the separator between the tags, or nil if rawName does not contain any tags.
+func (p *datadogParser) splitNameTags(rawName []byte) ([]byte, []byte, []byte) {
+	dialect := p.opts.TagDialect
+	if dialect == TagDialectAuto {
+		dialect = detectTagDialect(rawName)
+	}
+
+	switch dialect {
+	case TagDialectInflux:
+		return splitNameTagsAt(rawName, sepComma, sepComma)
+	case TagDialectLibrato:
+		return splitNameTagsAt(rawName, sepHash, sepComma)
+	case TagDialectGraphite:
+		return splitNameTagsAt(rawName, sepSemicolon, sepSemicolon)
+	case TagDialectSignalFx:
+		if bytes.HasSuffix(rawName, sepCloseBracket) {
+			if idx := bytes.LastIndex(rawName, sepOpenBracket); idx != -1 {
+				return rawName[:idx], rawName[idx+len(sepOpenBracket) : len(rawName)-len(sepCloseBracket)], sepComma
+			}
+		}
+		if bytes.HasPrefix(rawName, sepOpenBracket) {
+			if idx := bytes.Index(rawName, sepCloseBracket); idx != -1 {
+				return rawName[idx+len(sepCloseBracket):], rawName[len(sepOpenBracket):idx], sepComma
+			}
+		}
+	}
+	return rawName, nil, nil
+}
+
+// splitNameTagsAt splits rawName at the first occurrence of start into the metric name and its tags separated by sep.
+func splitNameTagsAt(rawName []byte, start []byte, sep []byte) ([]byte, []byte, []byte) {
+	idx := bytes.Index(rawName, start)
+	if idx == -1 {
+		return rawName, nil, nil
+	}
+	return rawName[:idx], rawName[idx+len(start):], sep
+}
+
+// detectTagDialect returns the tag dialect used by rawName, or TagDialectNone if it does not appear to contain tags.
+// Other than brackets, the dialect is that of the first separator in rawName, as tag values may contain the separators of other dialects.
+func detectTagDialect(rawName []byte) TagDialect {
+	if bytes.HasSuffix(rawName, sepCloseBracket) && bytes.Contains(rawName, sepOpenBracket) ||
+		bytes.HasPrefix(rawName, sepOpenBracket) && bytes.Contains(rawName, sepCloseBracket) {
+		return TagDialectSignalFx
+	}
+
+	for _, b := range rawName {
+		switch b {
+		case sepSemicolon[0]:
+			return TagDialectGraphite
+		case sepComma[0]:
+			return TagDialectInflux
+		case sepHash[0]:
+			return TagDialectLibrato
+		}
+	}
+	return TagDialectNone
+}
+
+// appendNameTag appends the tag b embedded in a metric name to dst in DogStatsD form,
+// replacing the first `=` between its key and value with a colon.
+func appendNameTag(dst []byte, b []byte) []byte {
+	if idx := bytes.Index(b, sepEquals); idx != -1 {
+		dst = append(dst, b[:idx]...)
+		dst = append(dst, sepColon...)
+		return append(dst, b[idx+len(sepEquals):]...)
+	}
+	return append(dst, b...)
+}
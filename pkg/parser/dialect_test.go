@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TagDialectSuite struct {
+	suite.Suite
+}
+
+func (s *TagDialectSuite) Test_Parse() {
+	for _, tc := range []struct {
+		dialect  TagDialect
+		input    string
+		name     string
+		expected []string
+	}{
+		{TagDialectInflux, "foo,env=dev,canary:1|c", "foo", []string{"env:dev", "canary"}},
+		{TagDialectInflux, "foo,env=dev:1|c|#app:bar", "foo", []string{"env:dev", "app:bar"}},
+		{TagDialectInflux, "foo#env=dev:1|c", "foo#env=dev", nil},
+		{TagDialectLibrato, "foo#env=dev,region=eu:1|c", "foo", []string{"env:dev", "region:eu"}},
+		{TagDialectSignalFx, "foo[env=dev,region=eu]:1|c", "foo", []string{"env:dev", "region:eu"}},
+		{TagDialectSignalFx, "[env=dev]foo:1|c", "foo", []string{"env:dev"}},
+		{TagDialectSignalFx, "fo[env=dev]o:1|c", "fo[env=dev]o", nil},
+		{TagDialectGraphite, "foo;env=dev;url=a=b:1|c", "foo", []string{"env:dev", "url:a=b"}},
+		{TagDialectAuto, "foo,env=dev:1|c", "foo", []string{"env:dev"}},
+		{TagDialectAuto, "foo#env=dev,a=b:1|c", "foo", []string{"env:dev", "a:b"}},
+		{TagDialectAuto, "foo[env=dev,a=b]:1|c", "foo", []string{"env:dev", "a:b"}},
+		{TagDialectAuto, "foo;env=dev;a=b,c:1|c", "foo", []string{"env:dev", "a:b,c"}},
+		{TagDialectAuto, "foo.bar:1|c|#env:dev", "foo.bar", []string{"env:dev"}},
+		{TagDialectAuto, "foo,,env=dev,:1|c", "foo", []string{"env:dev"}},
+		{TagDialectNone, "foo,env=dev:1|c", "foo,env=dev", nil},
+	} {
+		p := NewDatadogParserWithOptions(DatadogParserOptions{TagDialect: tc.dialect})
+		m, err := p.Parse([]byte(tc.input))
+		s.Require().NoError(err, tc.input)
+		s.Equal(tc.name, m.Name, tc.input)
+		s.Equal(tc.expected, m.Tags, tc.input)
+
+		var dst DatadogMetric
+		s.Require().NoError(p.ParseInto(&dst, []byte(tc.input)), tc.input)
+		s.Equal(tc.name, dst.Name, tc.input)
+		if tc.expected == nil {
+			s.Empty(dst.Tags, tc.input)
+		} else {
+			s.Equal(tc.expected, dst.Tags, tc.input)
+		}
+	}
+}
+
+func (s *TagDialectSuite) Test_ParseView() {
+	p := NewDatadogParserWithOptions(DatadogParserOptions{TagDialect: TagDialectGraphite})
+	var v DatadogMetricView
+	s.Require().NoError(p.ParseView(&v, []byte("foo;env=dev;a=b:1|c|#x")))
+	s.Equal("foo", string(v.Name))
+	s.Equal("env=dev;a=b", string(v.NameTags))
+	s.Equal([][]byte{[]byte("env=dev"), []byte("a=b"), []byte("x")}, v.AppendTags(nil))
+}
+
+func (s *TagDialectSuite) Test_ParseInto_Allocs() {
+	p := NewDatadogParserWithOptions(DatadogParserOptions{TagDialect: TagDialectInflux})
+	payload := []byte("foo,env=dev,region=eu:1|c")
+	var dst DatadogMetric
+	s.Require().NoError(p.ParseInto(&dst, payload))
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = p.ParseInto(&dst, payload)
+	})
+	s.Zero(allocs)
+}
+
+func (s *TagDialectSuite) Test_StatsD() {
+	p := NewDatadogParserWithOptions(DatadogParserOptions{Protocol: ProtocolStatsD, TagDialect: TagDialectAuto})
+	ms, errs := p.ParseMulti([]byte("foo,env=dev:1|c:2|ms"))
+	s.Require().Len(ms, 2)
+	s.Nil(errs[0])
+	s.Nil(errs[1])
+	s.Equal("foo", ms[1].Name)
+	s.Equal([]string{"env:dev"}, ms[1].Tags)
+}
+
+func (s *TagDialectSuite) Test_Limits() {
+	p := NewDatadogParserWithOptions(DatadogParserOptions{TagDialect: TagDialectInflux, MaxNameLength: 3, MaxTagLength: 5})
+	_, err := p.Parse([]byte("foo,env=dev:1|c"))
+	s.True(errors.Is(err, ErrTagTooLong))
+	s.Equal(4, err.(*ParseError).Offset)
+
+	m, err := p.Parse([]byte("foo,a=b:1|c"))
+	s.Require().NoError(err)
+	s.Equal("foo", m.Name)
+
+	p = NewDatadogParserWithOptions(DatadogParserOptions{TagDialect: TagDialectInflux, Strict: true})
+	_, err = p.Parse([]byte("foo,a=b,:1|c"))
+	s.True(errors.Is(err, ErrEmptyTag))
+}
+
+func (s *TagDialectSuite) Test_detectTagDialect() {
+	s.Equal(TagDialectNone, detectTagDialect([]byte("foo.bar")))
+	s.Equal(TagDialectInflux, detectTagDialect([]byte("foo,a=b")))
+	s.Equal(TagDialectLibrato, detectTagDialect([]byte("foo#a=b")))
+	s.Equal(TagDialectSignalFx, detectTagDialect([]byte("foo[a=b,c=d]")))
+	s.Equal(TagDialectSignalFx, detectTagDialect([]byte("[a=b]foo")))
+	s.Equal(TagDialectGraphite, detectTagDialect([]byte("foo;a=b")))
+	s.Equal(TagDialectGraphite, detectTagDialect([]byte("foo;a=b,c#d")))
+	s.Equal(TagDialectLibrato, detectTagDialect([]byte("foo#a=b,c=d")))
+}
+
+func TestTagDialectSuite(t *testing.T) {
+	suite.Run(t, new(TagDialectSuite))
+}
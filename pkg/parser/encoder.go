@@ -0,0 +1,238 @@
+package parser
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Marshal returns the DogStatsD wire format of m.
+// See AppendTo for details.
+func Marshal(m *DatadogMetric) ([]byte, error) {
+	return AppendTo(nil, m)
+}
+
+// AppendTo appends the DogStatsD wire format of m to dst and returns the extended slice.
+// Optional fields are written in a canonical order, and only if they differ from their defaults,
+// so that parsing the result yields a metric equal to m.
+// Returns ErrInvalidMetricType, ErrInvalidServiceCheckType, ErrInvalidEventPriority or ErrInvalidEventAlertType
+// if m has an unknown type, status, priority or alert type, in which case dst is returned unchanged.
+func AppendTo(dst []byte, m *DatadogMetric) ([]byte, error) {
+	switch m.Type {
+	case MetricEvent:
+		return appendEvent(dst, m)
+	case MetricServiceCheck:
+		return appendServiceCheck(dst, m)
+	default:
+		return appendMetric(dst, m)
+	}
+}
+
+// appendMetric appends a metric of the form name:value[:value...]|type[|@sample_rate][|#tags][|Ttimestamp][|c:...][|e:...][|card:...].
+func appendMetric(dst []byte, m *DatadogMetric) ([]byte, error) {
+	wireType, err := wireTypeOfMetric(m.Type)
+	if err != nil {
+		return dst, err
+	}
+
+	dst = append(dst, m.Name...)
+	dst = append(dst, sepColon...)
+	if len(m.Values) > 0 {
+		for i, value := range m.Values {
+			if i > 0 {
+				dst = append(dst, sepColon...)
+			}
+			dst = append(dst, value...)
+		}
+	} else {
+		dst = append(dst, m.Value...)
+	}
+	dst = append(dst, sepPipe...)
+	dst = append(dst, wireType...)
+
+	if m.SampleRate != 0 && m.SampleRate != 1 {
+		dst = append(dst, sepPipe...)
+		dst = append(dst, prefixSampleRate...)
+		dst = strconv.AppendFloat(dst, m.SampleRate, 'g', -1, 64)
+	}
+	dst = appendTags(dst, m.Tags)
+	if !m.Timestamp.IsZero() {
+		dst = append(dst, sepPipe...)
+		dst = append(dst, prefixTimestamp...)
+		dst = strconv.AppendInt(dst, m.Timestamp.Unix(), 10)
+	}
+	dst = appendOriginFields(dst, m)
+	return dst, nil
+}
+
+// appendEvent appends an event of the form _e{title_length,text_length}:title|text[|d:...][|h:...][|k:...][|p:...][|s:...][|t:...][|#tags][|c:...][|e:...][|card:...].
+// If m.Event is nil, the title, text, tags and timestamp are taken from m itself.
+func appendEvent(dst []byte, m *DatadogMetric) ([]byte, error) {
+	evt := m.Event
+	if evt == nil {
+		evt = &DatadogEvent{
+			Title:     m.Name,
+			Text:      m.Value,
+			Timestamp: m.Timestamp,
+			Tags:      m.Tags,
+		}
+	}
+
+	switch evt.Priority {
+	case "", EventPriorityNormal, EventPriorityLow:
+	default:
+		return dst, ErrInvalidEventPriority
+	}
+	switch evt.AlertType {
+	case "", EventAlertTypeError, EventAlertTypeWarning, EventAlertTypeInfo, EventAlertTypeSuccess:
+	default:
+		return dst, ErrInvalidEventAlertType
+	}
+
+	title := escapeEventText(evt.Title)
+	text := escapeEventText(evt.Text)
+	dst = append(dst, prefixEvent...)
+	dst = append(dst, sepOpenBrace...)
+	dst = strconv.AppendInt(dst, int64(len(title)), 10)
+	dst = append(dst, sepComma...)
+	dst = strconv.AppendInt(dst, int64(len(text)), 10)
+	dst = append(dst, sepCloseBrace...)
+	dst = append(dst, sepColon...)
+	dst = append(dst, title...)
+	dst = append(dst, sepPipe...)
+	dst = append(dst, text...)
+
+	if !evt.Timestamp.IsZero() {
+		dst = append(dst, sepPipe...)
+		dst = append(dst, prefixEventTimestamp...)
+		dst = strconv.AppendInt(dst, evt.Timestamp.Unix(), 10)
+	}
+	dst = appendStringField(dst, prefixEventHostname, evt.Hostname)
+	dst = appendStringField(dst, prefixEventAggregationKey, evt.AggregationKey)
+	if evt.Priority != EventPriorityNormal {
+		dst = appendStringField(dst, prefixEventPriority, string(evt.Priority))
+	}
+	dst = appendStringField(dst, prefixEventSourceType, evt.SourceType)
+	if evt.AlertType != EventAlertTypeInfo {
+		dst = appendStringField(dst, prefixEventAlertType, string(evt.AlertType))
+	}
+	dst = appendTags(dst, evt.Tags)
+	return appendOriginFields(dst, m), nil
+}
+
+// appendServiceCheck appends a service check of the form _sc|name|status[|d:...][|h:...][|#tags][|c:...][|e:...][|card:...][|m:message].
+// If m.ServiceCheck is nil, the name, status, tags and timestamp are taken from m itself.
+func appendServiceCheck(dst []byte, m *DatadogMetric) ([]byte, error) {
+	sc := m.ServiceCheck
+	if sc == nil {
+		sc = &DatadogServiceCheck{
+			Name:      m.Name,
+			Status:    ServiceCheckStatus(m.Value),
+			Timestamp: m.Timestamp,
+			Tags:      m.Tags,
+		}
+	}
+
+	wireStatus, err := wireTypeOfServiceCheck(sc.Status)
+	if err != nil {
+		return dst, err
+	}
+
+	dst = append(dst, prefixServiceCheck...)
+	dst = append(dst, sc.Name...)
+	dst = append(dst, sepPipe...)
+	dst = append(dst, wireStatus...)
+
+	if !sc.Timestamp.IsZero() {
+		dst = append(dst, sepPipe...)
+		dst = append(dst, prefixServiceCheckTimestamp...)
+		dst = strconv.AppendInt(dst, sc.Timestamp.Unix(), 10)
+	}
+	dst = appendStringField(dst, prefixServiceCheckHostname, sc.Hostname)
+	dst = appendTags(dst, sc.Tags)
+	dst = appendOriginFields(dst, m)
+	// the message must be the last field as it may contain pipes
+	if sc.Message != "" {
+		dst = appendStringField(dst, prefixServiceCheckMessage, escapeServiceCheckMessage(sc.Message))
+	}
+	return dst, nil
+}
+
+// appendTags appends a comma-separated tags field to dst if there are any tags.
+func appendTags(dst []byte, tags []string) []byte {
+	if len(tags) == 0 {
+		return dst
+	}
+	dst = append(dst, sepPipe...)
+	dst = append(dst, sepHash...)
+	for i, tag := range tags {
+		if i > 0 {
+			dst = append(dst, sepComma...)
+		}
+		dst = append(dst, tag...)
+	}
+	return dst
+}
+
+// appendOriginFields appends the container ID, external data and cardinality fields of m to dst if they are set.
+func appendOriginFields(dst []byte, m *DatadogMetric) []byte {
+	dst = appendStringField(dst, prefixContainerID, m.ContainerID)
+	dst = appendStringField(dst, prefixExternalData, m.ExternalData)
+	return appendStringField(dst, prefixCardinality, m.Cardinality)
+}
+
+// appendStringField appends a field consisting of prefix followed by value to dst if value is not empty.
+func appendStringField(dst []byte, prefix []byte, value string) []byte {
+	if value == "" {
+		return dst
+	}
+	dst = append(dst, sepPipe...)
+	dst = append(dst, prefix...)
+	return append(dst, value...)
+}
+
+// escapeEventText escapes newlines in an event title or text.
+func escapeEventText(s string) []byte {
+	return bytes.Replace([]byte(s), sepNewLine, escapedNewLine, -1)
+}
+
+// escapeServiceCheckMessage escapes newlines and `m:` sequences in a service check message.
+func escapeServiceCheckMessage(s string) string {
+	b := bytes.Replace([]byte(s), sepNewLine, escapedNewLine, -1)
+	return string(bytes.Replace(b, prefixServiceCheckMessage, escapedServiceCheckMessage, -1))
+}
+
+// wireTypeOfMetric returns the DogStatsD representation of a metric type.
+func wireTypeOfMetric(t MetricType) ([]byte, error) {
+	switch t {
+	case MetricGauge:
+		return typeGauge, nil
+	case MetricCount:
+		return typeCount, nil
+	case MetricHist:
+		return typeHistogram, nil
+	case MetricSet:
+		return typeSet, nil
+	case MetricTiming:
+		return typeTiming, nil
+	case MetricDistribution:
+		return typeDistribution, nil
+	default:
+		return nil, ErrInvalidMetricType
+	}
+}
+
+// wireTypeOfServiceCheck returns the DogStatsD representation of a service check status.
+func wireTypeOfServiceCheck(s ServiceCheckStatus) ([]byte, error) {
+	switch s {
+	case ServiceCheckOK:
+		return typeServiceCheckOK, nil
+	case ServiceCheckWarn:
+		return typeServiceCheckWarn, nil
+	case ServiceCheckCritical:
+		return typeServiceCheckCritical, nil
+	case ServiceCheckUnknown:
+		return typeServiceCheckUnknown, nil
+	default:
+		return nil, ErrInvalidServiceCheckType
+	}
+}
@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EncoderSuite struct {
+	suite.Suite
+}
+
+func (s *EncoderSuite) Test_Marshal() {
+	ts := time.Unix(1656581409, 0)
+	for _, tc := range []struct {
+		m        *DatadogMetric
+		expected string
+	}{
+		{&DatadogMetric{Name: "foo", Value: "1", Type: MetricCount}, "foo:1|c"},
+		{&DatadogMetric{Name: "foo", Value: "1", Type: MetricCount, SampleRate: 1}, "foo:1|c"},
+		{&DatadogMetric{Name: "foo", Values: []string{"1.2", "3.4"}, Type: MetricHist}, "foo:1.2:3.4|h"},
+		{&DatadogMetric{Name: "foo", Value: "-3", Type: MetricGauge, Relative: true}, "foo:-3|g"},
+		{&DatadogMetric{Name: "foo", Value: "bar", Type: MetricSet, Tags: []string{"a:b", "c"}}, "foo:bar|s|#a:b,c"},
+		{
+			&DatadogMetric{
+				Name:         "foo",
+				Value:        "12",
+				Type:         MetricDistribution,
+				Tags:         []string{"env:dev"},
+				SampleRate:   0.25,
+				Timestamp:    ts,
+				ContainerID:  "abc",
+				ExternalData: "it-false",
+				Cardinality:  "high",
+			},
+			"foo:12|d|@0.25|#env:dev|T1656581409|c:abc|e:it-false|card:high",
+		},
+		{
+			&DatadogMetric{Name: "title", Value: "text\nmore", Type: MetricEvent},
+			"_e{5,10}:title|text\\nmore",
+		},
+		{
+			&DatadogMetric{
+				Type:        MetricEvent,
+				ContainerID: "abc",
+				Event: &DatadogEvent{
+					Title:          "a|b",
+					Text:           "c#d",
+					Timestamp:      ts,
+					Hostname:       "host",
+					AggregationKey: "key",
+					Priority:       EventPriorityLow,
+					SourceType:     "src",
+					AlertType:      EventAlertTypeError,
+					Tags:           []string{"env:dev"},
+				},
+			},
+			"_e{3,3}:a|b|c#d|d:1656581409|h:host|k:key|p:low|s:src|t:error|#env:dev|c:abc",
+		},
+		{
+			&DatadogMetric{
+				Type:     MetricEvent,
+				Event:    &DatadogEvent{Title: "a", Text: "b", Priority: EventPriorityNormal, AlertType: EventAlertTypeInfo},
+				Name:     "ignored",
+				Relative: true,
+			},
+			"_e{1,1}:a|b",
+		},
+		{&DatadogMetric{Name: "db.up", Value: "CRITICAL", Type: MetricServiceCheck}, "_sc|db.up|2"},
+		{
+			&DatadogMetric{
+				Type:        MetricServiceCheck,
+				Cardinality: "low",
+				ServiceCheck: &DatadogServiceCheck{
+					Name:      "db.up",
+					Status:    ServiceCheckWarn,
+					Timestamp: ts,
+					Hostname:  "db1",
+					Message:   "slow|m:\nquery",
+					Tags:      []string{"env:prod"},
+				},
+			},
+			"_sc|db.up|1|d:1656581409|h:db1|#env:prod|card:low|m:slow|m\\:\\nquery",
+		},
+	} {
+		b, err := Marshal(tc.m)
+		s.Require().NoError(err, tc.expected)
+		s.Equal(tc.expected, string(b))
+	}
+}
+
+func (s *EncoderSuite) Test_AppendTo() {
+	dst := []byte("foo:1|c\n")
+	dst, err := AppendTo(dst, &DatadogMetric{Name: "bar", Value: "2", Type: MetricGauge})
+	s.Require().NoError(err)
+	s.Equal("foo:1|c\nbar:2|g", string(dst))
+}
+
+func (s *EncoderSuite) Test_AppendTo_Invalid() {
+	dst := []byte("foo")
+	b, err := AppendTo(dst, &DatadogMetric{Name: "bar", Value: "2", Type: "X"})
+	s.True(errors.Is(err, ErrInvalidMetricType))
+	s.Equal("foo", string(b))
+
+	b, err = AppendTo(dst, &DatadogMetric{Name: "bar", Value: "BAD", Type: MetricServiceCheck})
+	s.True(errors.Is(err, ErrInvalidServiceCheckType))
+	s.Equal("foo", string(b))
+
+	b, err = AppendTo(dst, &DatadogMetric{Type: MetricEvent, Event: &DatadogEvent{Title: "t", Text: "x", Priority: "urgent"}})
+	s.True(errors.Is(err, ErrInvalidEventPriority))
+	s.Equal("foo", string(b))
+
+	b, err = AppendTo(dst, &DatadogMetric{Type: MetricEvent, Event: &DatadogEvent{Title: "t", Text: "x", AlertType: "fatal"}})
+	s.True(errors.Is(err, ErrInvalidEventAlertType))
+	s.Equal("foo", string(b))
+}
+
+func (s *EncoderSuite) Test_RoundTrip() {
+	p := NewDatadogParser()
+	roundTrip := func(q quickMetric) bool {
+		b, err := Marshal(q.m)
+		if err != nil {
+			s.T().Logf("marshal %v: %s", q.m, err)
+			return false
+		}
+		m, err := p.Parse(b)
+		if err != nil {
+			s.T().Logf("parse %q: %s", b, err)
+			return false
+		}
+		if !reflect.DeepEqual(q.m, m) {
+			s.T().Logf("round trip of %q:\nexpected %#v\nactual   %#v", b, q.m, m)
+			return false
+		}
+		return true
+	}
+	s.NoError(quick.Check(roundTrip, &quick.Config{MaxCount: 2000}))
+}
+
+// quickMetric generates random metrics, events and service checks as Parse would return them.
+type quickMetric struct {
+	m *DatadogMetric
+}
+
+const quickSafeChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-/"
+
+// quickTextChars includes whitespace, which the lenient parser must not trim from event text or service check messages.
+const quickTextChars = quickSafeChars + "|#:,@m\n \t"
+
+// Generate implements quick.Generator.
+func (quickMetric) Generate(r *rand.Rand, size int) reflect.Value {
+	var m *DatadogMetric
+	switch r.Intn(4) {
+	case 0:
+		m = quickEvent(r, size)
+	case 1:
+		m = quickServiceCheck(r, size)
+	default:
+		m = quickMetricSample(r, size)
+	}
+	m.ContainerID = quickOptionalString(r, quickSafeChars, size)
+	m.ExternalData = quickOptionalString(r, quickSafeChars+":", size)
+	m.Cardinality = quickOptionalString(r, quickSafeChars, size)
+	return reflect.ValueOf(quickMetric{m: m})
+}
+
+func quickMetricSample(r *rand.Rand, size int) *DatadogMetric {
+	types := []MetricType{MetricGauge, MetricCount, MetricHist, MetricSet, MetricTiming, MetricDistribution}
+	m := &DatadogMetric{
+		Name:       quickString(r, quickSafeChars, 1+r.Intn(size+1)),
+		Type:       types[r.Intn(len(types))],
+		Tags:       quickTags(r, size),
+		SampleRate: 1,
+		Timestamp:  quickTimestamp(r),
+	}
+	if r.Intn(2) == 0 {
+		m.SampleRate = 1 - r.Float64()
+	}
+
+	if m.Type == MetricSet {
+		m.Values = []string{quickString(r, quickSafeChars, 1+r.Intn(size+1))}
+		m.Value = m.Values[0]
+		return m
+	}
+
+	m.Relative = m.Type == MetricGauge && r.Intn(2) == 0
+	for i := 0; i <= r.Intn(4); i++ {
+		f := r.NormFloat64() * math.Pow10(r.Intn(10))
+		if !m.Relative {
+			f = math.Abs(f)
+		}
+		value := strconv.FormatFloat(f, 'g', -1, 64)
+		if m.Relative && f >= 0 {
+			value = "+" + value
+		}
+		m.Values = append(m.Values, value)
+		m.FloatValues = append(m.FloatValues, f)
+	}
+	m.Value = m.Values[0]
+	m.FloatValue = m.FloatValues[0]
+	return m
+}
+
+func quickEvent(r *rand.Rand, size int) *DatadogMetric {
+	priorities := []EventPriority{EventPriorityNormal, EventPriorityLow}
+	alertTypes := []EventAlertType{EventAlertTypeError, EventAlertTypeWarning, EventAlertTypeInfo, EventAlertTypeSuccess}
+	evt := &DatadogEvent{
+		Title:          quickString(r, quickTextChars, r.Intn(size+1)),
+		Text:           quickString(r, quickTextChars, r.Intn(size+1)),
+		Timestamp:      quickTimestamp(r),
+		Hostname:       quickOptionalString(r, quickSafeChars, size),
+		AggregationKey: quickOptionalString(r, quickSafeChars, size),
+		Priority:       priorities[r.Intn(len(priorities))],
+		SourceType:     quickOptionalString(r, quickSafeChars, size),
+		AlertType:      alertTypes[r.Intn(len(alertTypes))],
+		Tags:           quickTags(r, size),
+	}
+	return &DatadogMetric{
+		Name:       evt.Title,
+		Value:      evt.Text,
+		Type:       MetricEvent,
+		Tags:       evt.Tags,
+		SampleRate: 1,
+		Timestamp:  evt.Timestamp,
+		Event:      evt,
+	}
+}
+
+func quickServiceCheck(r *rand.Rand, size int) *DatadogMetric {
+	statuses := []ServiceCheckStatus{ServiceCheckOK, ServiceCheckWarn, ServiceCheckCritical, ServiceCheckUnknown}
+	sc := &DatadogServiceCheck{
+		Name:      quickString(r, quickSafeChars, 1+r.Intn(size+1)),
+		Status:    statuses[r.Intn(len(statuses))],
+		Timestamp: quickTimestamp(r),
+		Hostname:  quickOptionalString(r, quickSafeChars, size),
+		Message:   quickOptionalString(r, quickTextChars, size),
+		Tags:      quickTags(r, size),
+	}
+	return &DatadogMetric{
+		Name:         sc.Name,
+		Value:        string(sc.Status),
+		Type:         MetricServiceCheck,
+		Tags:         sc.Tags,
+		SampleRate:   1,
+		Timestamp:    sc.Timestamp,
+		ServiceCheck: sc,
+	}
+}
+
+func quickTags(r *rand.Rand, size int) []string {
+	n := r.Intn(4)
+	if n == 0 {
+		return nil
+	}
+	tags := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		tags = append(tags, quickString(r, quickSafeChars+":#", 1+r.Intn(size+1)))
+	}
+	return tags
+}
+
+func quickTimestamp(r *rand.Rand) time.Time {
+	if r.Intn(2) == 0 {
+		return time.Time{}
+	}
+	return time.Unix(1+r.Int63n(1<<32), 0)
+}
+
+func quickOptionalString(r *rand.Rand, chars string, size int) string {
+	if r.Intn(2) == 0 {
+		return ""
+	}
+	return quickString(r, chars, 1+r.Intn(size+1))
+}
+
+func quickString(r *rand.Rand, chars string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = chars[r.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+func TestEncoderSuite(t *testing.T) {
+	suite.Run(t, new(EncoderSuite))
+}
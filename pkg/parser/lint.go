@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LintRule identifies a Datadog naming rule broken by a metric.
+type LintRule string
+
+const (
+	// LintNameStart is broken by a metric name which does not start with a letter.
+	LintNameStart LintRule = "name-start"
+	// LintNameLength is broken by a metric name longer than 200 characters.
+	LintNameLength LintRule = "name-length"
+	// LintNameChars is broken by a metric name containing characters other than ASCII letters, digits, underscores and periods.
+	LintNameChars LintRule = "name-chars"
+	// LintNameCase is broken by a metric name containing uppercase letters.
+	LintNameCase LintRule = "name-case"
+	// LintTagStart is broken by a tag which does not start with a letter, which may be any Unicode letter.
+	LintTagStart LintRule = "tag-start"
+	// LintTagLength is broken by a tag longer than 200 characters.
+	LintTagLength LintRule = "tag-length"
+	// LintTagChars is broken by a tag containing characters other than letters, digits, underscores, minuses, colons, periods and slashes.
+	LintTagChars LintRule = "tag-chars"
+	// LintTagCase is broken by a tag containing uppercase letters, which Datadog converts to lowercase.
+	LintTagCase LintRule = "tag-case"
+	// LintTagReserved is broken by a tag whose key is reserved by Datadog, such as host.
+	LintTagReserved LintRule = "tag-reserved"
+)
+
+// maxNameLength is the maximum length of a metric name accepted by Datadog.
+const maxNameLength = 200
+
+// maxTagLength is the maximum length of a tag accepted by Datadog.
+const maxTagLength = 200
+
+// reservedTagKeys holds the tag keys which Datadog assigns its own meaning to.
+var reservedTagKeys = map[string]bool{
+	"host":   true,
+	"device": true,
+	"source": true,
+}
+
+// LintWarning describes a metric name or tag which Datadog would rewrite or misinterpret.
+type LintWarning struct {
+	Rule LintRule
+	// Tag is the offending tag, or empty if the warning is about the metric name.
+	Tag     string
+	Message string
+}
+
+// String returns a string representation of a LintWarning.
+func (w LintWarning) String() string {
+	return fmt.Sprintf("%s: %s", w.Rule, w.Message)
+}
+
+// Lint checks the name and tags of m against the Datadog naming rules.
+// Returns nil if m breaks none of them. Event titles are not checked, as they are not metric names.
+func Lint(m *DatadogMetric) []LintWarning {
+	var warnings []LintWarning
+	if m.Type != MetricEvent {
+		warnings = lintName(warnings, m.Name)
+	}
+	for _, tag := range m.Tags {
+		warnings = lintTag(warnings, tag)
+	}
+	return warnings
+}
+
+// lintName appends a warning to warnings for each rule broken by name.
+func lintName(warnings []LintWarning, name string) []LintWarning {
+	if name == "" || !isLetter(name[0]) {
+		warnings = append(warnings, LintWarning{
+			Rule:    LintNameStart,
+			Message: fmt.Sprintf("name %q should start with a letter", name),
+		})
+	}
+	if len(name) > maxNameLength {
+		warnings = append(warnings, LintWarning{
+			Rule:    LintNameLength,
+			Message: fmt.Sprintf("name is %d characters long, more than the maximum of %d", len(name), maxNameLength),
+		})
+	}
+	if i := indexFunc(name, isNameChar); i != -1 {
+		r, _ := utf8.DecodeRuneInString(name[i:])
+		warnings = append(warnings, LintWarning{
+			Rule:    LintNameChars,
+			Message: fmt.Sprintf("name %q contains %q, which will be replaced with an underscore", name, r),
+		})
+	}
+	if indexFunc(name, isNotUpper) != -1 {
+		warnings = append(warnings, LintWarning{
+			Rule:    LintNameCase,
+			Message: fmt.Sprintf("name %q contains uppercase letters", name),
+		})
+	}
+	return warnings
+}
+
+// lintTag appends a warning to warnings for each rule broken by tag.
+func lintTag(warnings []LintWarning, tag string) []LintWarning {
+	if r, _ := utf8.DecodeRuneInString(tag); !unicode.IsLetter(r) {
+		warnings = append(warnings, LintWarning{
+			Rule:    LintTagStart,
+			Tag:     tag,
+			Message: fmt.Sprintf("tag %q should start with a letter", tag),
+		})
+	}
+	if len(tag) > maxTagLength {
+		warnings = append(warnings, LintWarning{
+			Rule:    LintTagLength,
+			Tag:     tag,
+			Message: fmt.Sprintf("tag is %d characters long, more than the maximum of %d", len(tag), maxTagLength),
+		})
+	}
+	if i := strings.IndexFunc(tag, isNotTagRune); i != -1 {
+		r, _ := utf8.DecodeRuneInString(tag[i:])
+		warnings = append(warnings, LintWarning{
+			Rule:    LintTagChars,
+			Tag:     tag,
+			Message: fmt.Sprintf("tag %q contains %q, which will be replaced with an underscore", tag, r),
+		})
+	}
+	if strings.IndexFunc(tag, unicode.IsUpper) != -1 {
+		warnings = append(warnings, LintWarning{
+			Rule:    LintTagCase,
+			Tag:     tag,
+			Message: fmt.Sprintf("tag %q contains uppercase letters, which will be converted to lowercase", tag),
+		})
+	}
+	if key := ParseTag(tag).Key; reservedTagKeys[key] {
+		warnings = append(warnings, LintWarning{
+			Rule:    LintTagReserved,
+			Tag:     tag,
+			Message: fmt.Sprintf("tag key %q is reserved by Datadog", key),
+		})
+	}
+	return warnings
+}
+
+// indexFunc returns the index of the first byte of s for which valid returns false, or -1 if there is none.
+func indexFunc(s string, valid func(c byte) bool) int {
+	for i := 0; i < len(s); i++ {
+		if !valid(s[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isNotTagRune returns true if r may not appear in a tag.
+// Invalid UTF-8 is decoded as utf8.RuneError, which may not appear in a tag.
+func isNotTagRune(r rune) bool {
+	return !isTagRune(r)
+}
+
+func isNotUpper(c byte) bool {
+	return !(c >= 'A' && c <= 'Z')
+}
+
+// isNameChar returns true if c may appear in a metric name.
+func isNameChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '_' || c == '.'
+}
+
+// isTagChar returns true if c may appear in a tag.
+func isTagChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '_' || c == '-' || c == ':' || c == '.' || c == '/'
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LintSuite struct {
+	suite.Suite
+}
+
+func (s *LintSuite) Test_Lint_Valid() {
+	s.Nil(Lint(&DatadogMetric{
+		Name: "my_app.requests.count",
+		Type: MetricCount,
+		Tags: []string{"env:prod", "url:/a/b-c", "canary"},
+	}))
+}
+
+func (s *LintSuite) Test_Lint_Name() {
+	for _, tc := range []struct {
+		name  string
+		rules []LintRule
+	}{
+		{"2xx.count", []LintRule{LintNameStart}},
+		{"", []LintRule{LintNameStart}},
+		{"_internal", []LintRule{LintNameStart}},
+		{"my app.count", []LintRule{LintNameChars}},
+		{"my-app.count", []LintRule{LintNameChars}},
+		{"MyApp.count", []LintRule{LintNameCase}},
+		{"a" + strings.Repeat("b", 200), []LintRule{LintNameLength}},
+		{"1 Bad", []LintRule{LintNameStart, LintNameChars, LintNameCase}},
+	} {
+		s.Equal(tc.rules, lintRules(Lint(&DatadogMetric{Name: tc.name, Type: MetricGauge})), tc.name)
+	}
+}
+
+func (s *LintSuite) Test_Lint_Tags() {
+	for _, tc := range []struct {
+		tag   string
+		rules []LintRule
+	}{
+		{"1env:prod", []LintRule{LintTagStart}},
+		{"", []LintRule{LintTagStart}},
+		{"env:prod us", []LintRule{LintTagChars}},
+		{"env:Prod", []LintRule{LintTagCase}},
+		{"a" + strings.Repeat("b", 200), []LintRule{LintTagLength}},
+		{"host:db1", []LintRule{LintTagReserved}},
+		{"device", []LintRule{LintTagReserved}},
+		{"source:app", []LintRule{LintTagReserved}},
+		{"hostname:db1", nil},
+		{"région:île", nil},
+		{"ÉTÉ", []LintRule{LintTagCase}},
+		{"env:\xff", []LintRule{LintTagChars}},
+	} {
+		s.Equal(tc.rules, lintRules(Lint(&DatadogMetric{Name: "foo", Type: MetricGauge, Tags: []string{tc.tag}})), tc.tag)
+	}
+
+	warnings := Lint(&DatadogMetric{Name: "foo", Tags: []string{"ok", "host:db1"}})
+	s.Require().Len(warnings, 1)
+	s.Equal("host:db1", warnings[0].Tag)
+	s.Equal(`tag-reserved: tag key "host" is reserved by Datadog`, warnings[0].String())
+}
+
+func (s *LintSuite) Test_Lint_Event() {
+	warnings := Lint(&DatadogMetric{Name: "Deploy finished!", Type: MetricEvent, Tags: []string{"Env:prod"}})
+	s.Equal([]LintRule{LintTagCase}, lintRules(warnings))
+}
+
+func (s *LintSuite) Test_Lint_ServiceCheck() {
+	warnings := Lint(&DatadogMetric{Name: "db up", Type: MetricServiceCheck})
+	s.Equal([]LintRule{LintNameChars}, lintRules(warnings))
+	s.Equal(`name "db up" contains ' ', which will be replaced with an underscore`, warnings[0].Message)
+}
+
+func (s *LintSuite) Test_Lint_NonASCIIName() {
+	warnings := Lint(&DatadogMetric{Name: "café.visits", Type: MetricCount})
+	s.Equal([]LintRule{LintNameChars}, lintRules(warnings))
+	s.Equal(`name "café.visits" contains 'é', which will be replaced with an underscore`, warnings[0].Message)
+}
+
+// lintRules returns the rule of each warning.
+func lintRules(warnings []LintWarning) []LintRule {
+	var rules []LintRule
+	for _, w := range warnings {
+		rules = append(rules, w.Rule)
+	}
+	return rules
+}
+
+func TestLintSuite(t *testing.T) {
+	suite.Run(t, new(LintSuite))
+}
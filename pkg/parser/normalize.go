@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NormalizeName returns name as Datadog stores it: leading characters other than ASCII letters are removed,
+// characters other than ASCII letters, digits, underscores and periods are replaced with an underscore,
+// consecutive underscores are collapsed, trailing underscores are removed, and the result is truncated to 200 characters.
+// Unlike tags, metric names keep their case.
+func NormalizeName(name string) string {
+	return normalize(name, maxNameLength, isLetterRune, func(r rune) rune {
+		if r < utf8.RuneSelf && isNameChar(byte(r)) {
+			return r
+		}
+		return '_'
+	})
+}
+
+// NormalizeTag returns tag as Datadog stores it: the tag is converted to lowercase, leading characters other than letters are removed,
+// characters other than letters, digits, underscores, minuses, colons, periods and slashes are replaced with an underscore,
+// consecutive underscores are collapsed, trailing underscores are removed, and the result is truncated to 200 bytes.
+func NormalizeTag(tag string) string {
+	return normalize(tag, maxTagLength, unicode.IsLetter, func(r rune) rune {
+		r = unicode.ToLower(r)
+		if isTagRune(r) {
+			return r
+		}
+		return '_'
+	})
+}
+
+// Normalized returns a copy of a metric with its name and tags normalized as Datadog would store them.
+// Tags which are empty once normalized are dropped. Event titles are not normalized, as they are not metric names.
+func (d *DatadogMetric) Normalized() *DatadogMetric {
+	n := *d
+	if d.Type != MetricEvent {
+		n.Name = NormalizeName(d.Name)
+	}
+	n.Tags = normalizeTags(d.Tags)
+
+	if d.ServiceCheck != nil {
+		sc := *d.ServiceCheck
+		sc.Name = n.Name
+		sc.Tags = n.Tags
+		n.ServiceCheck = &sc
+	}
+	if d.Event != nil {
+		evt := *d.Event
+		evt.Tags = n.Tags
+		n.Event = &evt
+	}
+	return &n
+}
+
+// normalizeTags returns tags normalized, excluding tags which are empty once normalized.
+func normalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = NormalizeTag(tag); tag != "" {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// normalize maps each rune of s using replace after removing leading runes for which start returns false,
+// collapses consecutive underscores, and truncates the result to maxLen bytes without splitting a rune.
+func normalize(s string, maxLen int, start func(r rune) bool, replace func(r rune) rune) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range s {
+		// invalid UTF-8 is decoded as utf8.RuneError, which is neither a letter nor allowed by replace
+		if b.Len() == 0 && !start(r) {
+			continue
+		}
+		r = replace(r)
+		if r == '_' {
+			if underscore {
+				continue
+			}
+			underscore = true
+		} else {
+			underscore = false
+		}
+		if b.Len()+utf8.RuneLen(r) > maxLen {
+			break
+		}
+		b.WriteRune(r)
+	}
+	return strings.TrimRight(b.String(), "_")
+}
+
+// isLetterRune returns true if r is an ASCII letter.
+func isLetterRune(r rune) bool {
+	return r < utf8.RuneSelf && isLetter(byte(r))
+}
+
+// isTagRune returns true if r may appear in a tag.
+func isTagRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return isTagChar(byte(r))
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
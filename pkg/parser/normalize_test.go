@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NormalizeSuite struct {
+	suite.Suite
+}
+
+func (s *NormalizeSuite) Test_NormalizeName() {
+	for _, tc := range []struct {
+		input    string
+		expected string
+	}{
+		{"my_app.requests", "my_app.requests"},
+		{"MyApp.Requests", "MyApp.Requests"},
+		{"my app.requests", "my_app.requests"},
+		{"my-app..requests", "my_app..requests"},
+		{"my  app", "my_app"},
+		{"my__app", "my_app"},
+		{"2xx.count", "xx.count"},
+		{"_.-foo", "foo"},
+		{"foo!!", "foo"},
+		{"café.visits", "caf_.visits"},
+		{"éfoo", "foo"},
+		{"123", ""},
+		{"", ""},
+		{"a" + strings.Repeat("b", 250), "a" + strings.Repeat("b", 199)},
+		{"a" + strings.Repeat("b", 198) + "  c", "a" + strings.Repeat("b", 198)},
+	} {
+		s.Equal(tc.expected, NormalizeName(tc.input), tc.input)
+	}
+}
+
+func (s *NormalizeSuite) Test_NormalizeTag() {
+	for _, tc := range []struct {
+		input    string
+		expected string
+	}{
+		{"env:prod", "env:prod"},
+		{"Env:Prod", "env:prod"},
+		{"url:/a/b-c.d", "url:/a/b-c.d"},
+		{"env:prod us", "env:prod_us"},
+		{"env:prod!!us", "env:prod_us"},
+		{"env:prod__", "env:prod"},
+		{"1env:prod", "env:prod"},
+		{":env", "env"},
+		{"région:île", "région:île"},
+		{"ÉTÉ", "été"},
+		{"env:\xffprod", "env:_prod"},
+		{"!!!", ""},
+		{"a" + strings.Repeat("é", 150), "a" + strings.Repeat("é", 99)},
+	} {
+		s.Equal(tc.expected, NormalizeTag(tc.input), tc.input)
+	}
+}
+
+func (s *NormalizeSuite) Test_Normalized() {
+	m := &DatadogMetric{
+		Name:  "My App.count",
+		Value: "1",
+		Type:  MetricCount,
+		Tags:  []string{"Env:Prod", "!!", "canary"},
+	}
+	n := m.Normalized()
+	s.Equal("My_App.count", n.Name)
+	s.Equal([]string{"env:prod", "canary"}, n.Tags)
+	s.Equal("1", n.Value)
+	// the original is unchanged
+	s.Equal("My App.count", m.Name)
+	s.Equal([]string{"Env:Prod", "!!", "canary"}, m.Tags)
+}
+
+func (s *NormalizeSuite) Test_Normalized_ServiceCheck() {
+	sc := &DatadogServiceCheck{Name: "db up", Status: ServiceCheckOK, Tags: []string{"Env:Prod"}}
+	m := &DatadogMetric{Name: sc.Name, Type: MetricServiceCheck, Tags: sc.Tags, ServiceCheck: sc}
+	n := m.Normalized()
+	s.Equal("db_up", n.Name)
+	s.Equal("db_up", n.ServiceCheck.Name)
+	s.Equal([]string{"env:prod"}, n.ServiceCheck.Tags)
+	s.Equal("db up", sc.Name)
+}
+
+func (s *NormalizeSuite) Test_Normalized_Event() {
+	evt := &DatadogEvent{Title: "Deploy finished!", Tags: []string{"Env:Prod"}}
+	m := &DatadogMetric{Name: evt.Title, Type: MetricEvent, Tags: evt.Tags, Event: evt}
+	n := m.Normalized()
+	s.Equal("Deploy finished!", n.Name)
+	s.Equal([]string{"env:prod"}, n.Event.Tags)
+	s.Equal([]string{"Env:Prod"}, evt.Tags)
+}
+
+func (s *NormalizeSuite) Test_NormalizedPassesLint() {
+	for _, name := range []string{"2 Bad-Name", "ok.name", "x!y"} {
+		m := &DatadogMetric{Name: name, Type: MetricGauge, Tags: []string{"1 Bad:Tag", "région:Île"}}
+		n := m.Normalized()
+		for _, w := range Lint(n) {
+			s.NotEqual(LintNameStart, w.Rule, name)
+			s.NotEqual(LintNameChars, w.Rule, name)
+			s.NotContains([]LintRule{LintTagStart, LintTagChars, LintTagCase}, w.Rule, name)
+		}
+	}
+}
+
+func TestNormalizeSuite(t *testing.T) {
+	suite.Run(t, new(NormalizeSuite))
+}
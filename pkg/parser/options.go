@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"bytes"
+)
+
+// UnknownFieldPolicy determines how a parser handles fields it does not recognize.
+// Can be one of:
+// - UnknownFieldDefault - ignore unknown fields unless the parser is strict
+// - UnknownFieldIgnore - always ignore unknown fields
+// - UnknownFieldReject - always return ErrUnknownField
+type UnknownFieldPolicy int
+
+const (
+	// UnknownFieldDefault ignores unknown fields in lenient mode and rejects them in strict mode.
+	UnknownFieldDefault UnknownFieldPolicy = iota
+	// UnknownFieldIgnore ignores unknown fields, as the Datadog agent does.
+	UnknownFieldIgnore
+	// UnknownFieldReject returns ErrUnknownField upon encountering an unknown field.
+	UnknownFieldReject
+)
+
+// DatadogParserOptions configures a DatadogParser.
+// The zero value is a lenient DogStatsD parser that accepts any metric type and length.
+type DatadogParserOptions struct {
+	// Protocol is the dialect of StatsD to parse. Defaults to ProtocolDogStatsD.
+	Protocol Protocol
+	// TagDialect is the convention used to embed tags in metric names, if any.
+	// Tags read from metric names are converted to DogStatsD form and precede any DogStatsD tags.
+	TagDialect TagDialect
+	// Strict causes any deviation from the protocol to be returned as an error.
+	// Otherwise the parser is as forgiving as the Datadog agent: unknown fields are ignored,
+	// empty fields and tags are dropped, the last of a repeated field wins and trailing whitespace
+	// is trimmed, except from the text of an event or the message of a service check.
+	Strict bool
+	// MaxNameLength is the maximum length of a metric or service check name in bytes. Zero means no limit.
+	MaxNameLength int
+	// MaxTagLength is the maximum length of a single tag in bytes. Zero means no limit.
+	MaxTagLength int
+	// AllowedTypes restricts the metric types accepted by the parser. Nil means all types are allowed.
+	AllowedTypes []MetricType
+	// UnknownFields determines how fields which are not recognized are handled.
+	UnknownFields UnknownFieldPolicy
+}
+
+// NewDatadogParserWithOptions returns a new instance of DatadogParser configured by opts.
+func NewDatadogParserWithOptions(opts DatadogParserOptions) DatadogParser {
+	return &datadogParser{
+		opts: opts,
+	}
+}
+
+var trailingWhitespace = " \t\r\n"
+
+// trim removes trailing whitespace from payload unless the parser is strict.
+func (p *datadogParser) trim(payload []byte) []byte {
+	if p.opts.Strict {
+		return payload
+	}
+	return bytes.TrimRight(payload, trailingWhitespace)
+}
+
+// rejectUnknownFields returns true if unknown fields should be returned as an error.
+func (p *datadogParser) rejectUnknownFields() bool {
+	switch p.opts.UnknownFields {
+	case UnknownFieldIgnore:
+		return false
+	case UnknownFieldReject:
+		return true
+	default:
+		return p.opts.Strict
+	}
+}
+
+// fieldKind identifies an optional field of a metric, event or service check.
+// Kinds are distinct bits, so a set of kinds can be held in a single fieldKind.
+type fieldKind uint16
+
+const (
+	fieldTags fieldKind = 1 << iota
+	fieldSampleRate
+	fieldTimestamp
+	fieldHostname
+	fieldAggregationKey
+	fieldPriority
+	fieldSourceType
+	fieldAlertType
+	fieldContainerID
+	fieldExternalData
+	fieldCardinality
+)
+
+// checkDuplicateField returns ErrDuplicateField if the parser is strict and kind is already in seen,
+// and otherwise adds kind to seen. A zero kind is never a duplicate.
+func (p *datadogParser) checkDuplicateField(seen *fieldKind, kind fieldKind) error {
+	if p.opts.Strict && *seen&kind != 0 {
+		return ErrDuplicateField
+	}
+	*seen |= kind
+	return nil
+}
+
+// checkEmptyField returns an error for an empty field if the parser is strict.
+// rest is the remainder of the payload following the field.
+func (p *datadogParser) checkEmptyField(rest []byte) error {
+	if !p.opts.Strict {
+		return nil
+	}
+	if rest == nil {
+		return ErrInvalidTrailingPipe
+	}
+	return ErrEmptyField
+}
+
+// checkType returns ErrMetricTypeNotAllowed if t is not one of the allowed metric types.
+func (p *datadogParser) checkType(t MetricType) error {
+	if p.opts.AllowedTypes == nil {
+		return nil
+	}
+	for _, allowed := range p.opts.AllowedTypes {
+		if t == allowed {
+			return nil
+		}
+	}
+	return ErrMetricTypeNotAllowed
+}
+
+// checkName returns ErrNameTooLong if name exceeds the maximum name length.
+func (p *datadogParser) checkName(name []byte) error {
+	if p.opts.MaxNameLength > 0 && len(name) > p.opts.MaxNameLength {
+		return errorAt(ErrNameTooLong, name)
+	}
+	return nil
+}
+
+// checkTags checks each of the tags in b separated by sep against the maximum tag length,
+// and rejects empty tags if the parser is strict.
+func (p *datadogParser) checkTags(b []byte, sep []byte) error {
+	if len(b) == 0 {
+		if p.opts.Strict {
+			return errorAt(ErrEmptyTag, b)
+		}
+		return nil
+	}
+	for rest := b; rest != nil; {
+		var tag []byte
+		tag, rest = nextToken(rest, sep)
+		if len(tag) == 0 && p.opts.Strict {
+			return errorAt(ErrEmptyTag, tag)
+		}
+		if p.opts.MaxTagLength > 0 && len(tag) > p.opts.MaxTagLength {
+			return errorAt(ErrTagTooLong, tag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DatadogParserOptionsSuite struct {
+	suite.Suite
+}
+
+func (s *DatadogParserOptionsSuite) Test_Lenient() {
+	p := NewDatadogParserWithOptions(DatadogParserOptions{})
+	m, err := p.Parse([]byte("foo:1|c||#a,,b,|g|x:y \r\n"))
+	s.Require().NoError(err)
+	s.Equal("foo", m.Name)
+	s.Equal(MetricCount, m.Type)
+	s.Equal([]string{"a", "b"}, m.Tags)
+
+	var dst DatadogMetric
+	s.Require().NoError(p.ParseInto(&dst, []byte("foo:1|c|#a,,b\t")))
+	s.Equal([]string{"a", "b"}, dst.Tags)
+
+	var v DatadogMetricView
+	s.Require().NoError(p.ParseView(&v, []byte("foo:1|c|#a,,b ")))
+	s.Equal([][]byte{[]byte("a"), []byte("b")}, v.AppendTags(nil))
+
+	// the message of a service check is never trimmed
+	m, err = p.Parse([]byte("_sc|foo|0|x:y|#a,|m:bar "))
+	s.Require().NoError(err)
+	s.Equal([]string{"a"}, m.Tags)
+	s.Equal("bar ", m.ServiceCheck.Message)
+
+	m, err = p.Parse([]byte("_sc|foo|0|#a \r"))
+	s.Require().NoError(err)
+	s.Equal([]string{"a"}, m.Tags)
+
+	m, err = p.Parse([]byte("_e{1,1}:a|b|x:y|#,a"))
+	s.Require().NoError(err)
+	s.Equal([]string{"a"}, m.Tags)
+
+	// only whitespace following the declared text of an event is trimmed
+	for _, input := range []string{"_e{1,2}:a|b ", "_e{1,2}:a|b \r\n", "_e{1,2}:a|b |#a \t"} {
+		m, err = p.Parse([]byte(input))
+		s.Require().NoError(err, input)
+		s.Equal("b ", m.Event.Text, input)
+	}
+
+	b, err := Marshal(&DatadogMetric{Type: MetricEvent, Event: &DatadogEvent{Title: "build", Text: "done "}})
+	s.Require().NoError(err)
+	m, err = p.Parse(b)
+	s.Require().NoError(err)
+	s.Equal("done ", m.Event.Text)
+}
+
+func (s *DatadogParserOptionsSuite) Test_Strict() {
+	p := NewDatadogParserWithOptions(DatadogParserOptions{Strict: true})
+	for _, tc := range []struct {
+		input  string
+		err    error
+		offset int
+	}{
+		{"foo:1|c ", ErrInvalidMetricType, 6},
+		{"foo:1||c", ErrEmptyField, 6},
+		{"foo:1|c|", ErrInvalidTrailingPipe, 8},
+		{"foo:1|c|g", ErrUnknownField, 8},
+		{"foo:1|c|#a,,b", ErrEmptyTag, 11},
+		{"foo:1|c|#", ErrEmptyTag, 9},
+		{"_sc|foo|0|x:y", ErrUnknownField, 10},
+		{"_sc|foo|0||#a", ErrEmptyField, 10},
+		{"_e{1,1}:a|b|x:y", ErrUnknownField, 12},
+		{"_e{1,1}:a|b|#a,", ErrEmptyTag, 15},
+		{"foo:1|c|#a|#b", ErrDuplicateField, 11},
+		{"foo:1|c|@0.5|@0.25", ErrDuplicateField, 13},
+		{"foo:1|T1656581409|c|T1656581410", ErrDuplicateField, 20},
+		{"foo:1|c|c:abc|c:def", ErrDuplicateField, 14},
+		{"foo:1|c|e:it-false|e:it-true", ErrDuplicateField, 19},
+		{"foo:1|c|card:low|card:high", ErrDuplicateField, 17},
+		{"_sc|foo|0|d:1656581409|d:1656581410", ErrDuplicateField, 23},
+		{"_sc|foo|0|h:a|#a|h:b|m:c", ErrDuplicateField, 17},
+		{"_sc|foo|0|#a|c:abc|#b", ErrDuplicateField, 19},
+		{"_e{1,1}:a|b|d:1656581409|d:1656581410", ErrDuplicateField, 25},
+		{"_e{1,1}:a|b|h:a|h:b", ErrDuplicateField, 16},
+		{"_e{1,1}:a|b|#a|#b", ErrDuplicateField, 15},
+		{"_e{1,1}:a|b|card:low|card:low", ErrDuplicateField, 21},
+	} {
+		m, err := p.Parse([]byte(tc.input))
+		s.Nil(m, tc.input)
+		s.Require().IsType(&ParseError{}, err, tc.input)
+		s.True(errors.Is(err, tc.err), tc.input)
+		s.Equal(tc.offset, err.(*ParseError).Offset, tc.input)
+		s.NotEmpty(err.(*ParseError).Hint, tc.input)
+	}
+
+	m, err := p.Parse([]byte("foo:1|c|#a,b|@0.5|c:abc"))
+	s.Require().NoError(err)
+	s.Equal([]string{"a", "b"}, m.Tags)
+
+	// a lenient parser keeps the last of a repeated field, as the agent does
+	m, err = NewDatadogParser().Parse([]byte("foo:1|c|#a|#b|@0.5|@0.25"))
+	s.Require().NoError(err)
+	s.Equal([]string{"b"}, m.Tags)
+	s.Equal(0.25, m.SampleRate)
+}
+
+func (s *DatadogParserOptionsSuite) Test_UnknownFields() {
+	p := NewDatadogParserWithOptions(DatadogParserOptions{UnknownFields: UnknownFieldReject})
+	_, err := p.Parse([]byte("foo:1|c|g"))
+	s.True(errors.Is(err, ErrUnknownField))
+	_, err = p.Parse([]byte("foo:1|c|#a,,b "))
+	s.NoError(err)
+
+	p = NewDatadogParserWithOptions(DatadogParserOptions{Strict: true, UnknownFields: UnknownFieldIgnore})
+	_, err = p.Parse([]byte("foo:1|c|g"))
+	s.NoError(err)
+	_, err = p.Parse([]byte("foo:1|c|#a,,b"))
+	s.True(errors.Is(err, ErrEmptyTag))
+}
+
+func (s *DatadogParserOptionsSuite) Test_MaxLengths() {
+	p := NewDatadogParserWithOptions(DatadogParserOptions{MaxNameLength: 3, MaxTagLength: 5})
+	for _, tc := range []struct {
+		input  string
+		err    error
+		offset int
+	}{
+		{"fooo:1|c", ErrNameTooLong, 0},
+		{"foo:1|c|#a:b,env:dev", ErrTagTooLong, 13},
+		{"_sc|fooo|0", ErrNameTooLong, 4},
+		{"_sc|foo|0|#env:dev", ErrTagTooLong, 11},
+		{"_e{1,1}:a|b|#env:dev", ErrTagTooLong, 13},
+	} {
+		_, err := p.Parse([]byte(tc.input))
+		s.Require().IsType(&ParseError{}, err, tc.input)
+		s.True(errors.Is(err, tc.err), tc.input)
+		s.Equal(tc.offset, err.(*ParseError).Offset, tc.input)
+	}
+
+	m, err := p.Parse([]byte("foo:1|c|#a:b,env:d"))
+	s.Require().NoError(err)
+	s.Equal([]string{"a:b", "env:d"}, m.Tags)
+}
+
+func (s *DatadogParserOptionsSuite) Test_AllowedTypes() {
+	p := NewDatadogParserWithOptions(DatadogParserOptions{AllowedTypes: []MetricType{MetricCount, MetricEvent}})
+	for _, input := range []string{"foo:1|c", "_e{1,1}:a|b"} {
+		_, err := p.Parse([]byte(input))
+		s.NoError(err, input)
+	}
+	for _, input := range []string{"foo:1|g", "foo:1|d", "_sc|foo|0"} {
+		_, err := p.Parse([]byte(input))
+		s.True(errors.Is(err, ErrMetricTypeNotAllowed), input)
+	}
+
+	var v DatadogMetricView
+	err := p.ParseView(&v, []byte("foo:1|ms"))
+	s.True(errors.Is(err, ErrMetricTypeNotAllowed))
+	s.Equal(6, err.(*ParseError).Offset)
+}
+
+func TestDatadogParserOptionsSuite(t *testing.T) {
+	suite.Run(t, new(DatadogParserOptionsSuite))
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"bytes"
+)
+
+// Protocol is the dialect of StatsD understood by a parser.
+// Can be one of:
+// - ProtocolDogStatsD ("dogstatsd") - DogStatsD, the default
+// - ProtocolStatsD ("statsd") - plain Etsy StatsD
+type Protocol string
+
+const (
+	// ProtocolDogStatsD is the DogStatsD protocol, with tags, events and service checks.
+	ProtocolDogStatsD Protocol = "dogstatsd"
+	// ProtocolStatsD is the plain Etsy StatsD protocol, without tags, events or service checks.
+	// A line may contain several metrics sharing a name, e.g. `foo:1|c:2|ms`.
+	ProtocolStatsD Protocol = "statsd"
+)
+
+// parseEachStatsD parses each metric of a StatsD line, calling fn with the result of each one in turn.
+// Returns false if fn returns false.
+func (p *datadogParser) parseEachStatsD(line []byte, lineNum int, fn func(m *DatadogMetric, err *ParseError) bool) bool {
+	name, rest, err := p.splitStatsDName(p.trim(line))
+	if err != nil {
+		return fn(nil, newParseError(line, lineNum, err))
+	}
+
+	for rest != nil {
+		var bit []byte
+		bit, rest = nextToken(rest, sepColon)
+		var v DatadogMetricView
+		if err := p.parseStatsDView(&v, name, bit); err != nil {
+			if !fn(nil, newParseError(line, lineNum, err)) {
+				return false
+			}
+			continue
+		}
+		m := &DatadogMetric{}
+		v.copyInto(m)
+		if !fn(m, nil) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseStatsDMetricView parses a StatsD line containing a single metric into v without allocating.
+func (p *datadogParser) parseStatsDMetricView(v *DatadogMetricView, payload []byte) error {
+	name, rest, err := p.splitStatsDName(payload)
+	if err != nil {
+		*v = DatadogMetricView{}
+		return err
+	}
+
+	bit, next := nextToken(rest, sepColon)
+	if next != nil {
+		*v = DatadogMetricView{}
+		return errorAt(ErrMultipleMetrics, next)
+	}
+	return p.parseStatsDView(v, name, bit)
+}
+
+// splitStatsDName splits a StatsD line into the metric name and the colon-separated metrics following it.
+func (p *datadogParser) splitStatsDName(line []byte) ([]byte, []byte, error) {
+	if len(line) == 0 {
+		return nil, nil, errorAt(ErrEmptyPayload, line)
+	}
+
+	name, rest := nextToken(line, sepColon)
+	if rest == nil {
+		// point at the end of the name where the colon is missing
+		if idx := bytes.Index(line, sepPipe); idx != -1 {
+			return nil, nil, errorAt(ErrNoValSep, line[idx:])
+		}
+		return nil, nil, errorAt(ErrNoValSep, line[len(line):])
+	}
+	return name, rest, nil
+}
+
+// parseStatsDView parses a single StatsD metric named name into v without allocating.
+func (p *datadogParser) parseStatsDView(v *DatadogMetricView, name, bit []byte) error {
+	// value|type[|@sample_rate]
+	*v = DatadogMetricView{
+		SampleRate: 1,
+	}
+	if err := p.parseName(v, name); err != nil {
+		return err
+	}
+
+	rawValue, rest := nextField(bit)
+	if rest == nil {
+		return errorAt(ErrNoTypeSep, bit[len(bit):])
+	}
+
+	// unlike DogStatsD, the type must immediately follow the value
+	var rawMetricType []byte
+	var seen fieldKind
+	for rest != nil {
+		var field []byte
+		var kind fieldKind
+		var err error
+		field, rest = nextField(rest)
+		switch {
+		case len(field) == 0:
+			err = p.checkEmptyField(rest)
+		case rawMetricType == nil:
+			rawMetricType = field
+		case bytes.HasPrefix(field, prefixSampleRate):
+			kind = fieldSampleRate
+			v.SampleRate, err = p.parseSampleRate(field[len(prefixSampleRate):])
+		case p.rejectUnknownFields():
+			err = ErrUnknownField
+		}
+		if err == nil {
+			err = p.checkDuplicateField(&seen, kind)
+		}
+		if err != nil {
+			return errorAt(err, field)
+		}
+	}
+
+	if rawMetricType == nil {
+		return errorAt(ErrInvalidTrailingPipe, bit[len(bit)-len(sepPipe):])
+	}
+
+	metricType, err := p.typeOfMetric(rawMetricType)
+	if err == nil {
+		err = p.checkType(metricType)
+	}
+	if err != nil {
+		return errorAt(err, rawMetricType)
+	}
+	v.Type = metricType
+	v.Value = rawValue
+	v.RawValues = rawValue
+
+	// sets count unique occurrences of arbitrary strings, so their values are not numeric
+	if metricType != MetricSet {
+		if err := p.parseNumericValues(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type StatsDSuite struct {
+	suite.Suite
+	p DatadogParser
+}
+
+func (s *StatsDSuite) SetupTest() {
+	s.p = NewDatadogParserWithOptions(DatadogParserOptions{Protocol: ProtocolStatsD})
+}
+
+func (s *StatsDSuite) Test_Parse() {
+	m, err := s.p.Parse([]byte("glork:320|ms|@0.1"))
+	s.Require().NoError(err)
+	s.EqualValues(&DatadogMetric{
+		Name:        "glork",
+		Value:       "320",
+		Values:      []string{"320"},
+		FloatValue:  320,
+		FloatValues: []float64{320},
+		Type:        MetricTiming,
+		SampleRate:  0.1,
+	}, m)
+}
+
+func (s *StatsDSuite) Test_Parse_GaugeDelta() {
+	m, err := s.p.Parse([]byte("gaugor:-10|g"))
+	s.Require().NoError(err)
+	s.Equal(MetricGauge, m.Type)
+	s.Equal(-10.0, m.FloatValue)
+	s.True(m.Relative)
+
+	m, err = s.p.Parse([]byte("gaugor:+4|g"))
+	s.Require().NoError(err)
+	s.True(m.Relative)
+}
+
+func (s *StatsDSuite) Test_Parse_Set() {
+	m, err := s.p.Parse([]byte("uniques:765|s"))
+	s.Require().NoError(err)
+	s.Equal(MetricSet, m.Type)
+	s.Equal("765", m.Value)
+	s.Nil(m.FloatValues)
+}
+
+func (s *StatsDSuite) Test_Parse_NoTags() {
+	// tags are not part of the protocol, so a name containing a hash is kept as is
+	m, err := s.p.Parse([]byte("foo#bar:1|c|#canary"))
+	s.Require().NoError(err)
+	s.Equal("foo#bar", m.Name)
+	s.Nil(m.Tags)
+
+	p := NewDatadogParserWithOptions(DatadogParserOptions{Protocol: ProtocolStatsD, Strict: true})
+	_, err = p.Parse([]byte("foo:1|c|#canary"))
+	s.True(errors.Is(err, ErrUnknownField))
+	_, err = p.Parse([]byte("foo:1|c|@0.5|@0.25"))
+	s.True(errors.Is(err, ErrDuplicateField))
+}
+
+func (s *StatsDSuite) Test_Parse_NoEvents() {
+	_, err := s.p.Parse([]byte("_e{1,1}:a|b"))
+	s.True(errors.Is(err, ErrInvalidMetricType))
+
+	_, err = s.p.Parse([]byte("_sc|foo|0"))
+	s.True(errors.Is(err, ErrNoValSep))
+}
+
+func (s *StatsDSuite) Test_Parse_Errors() {
+	for _, tc := range []struct {
+		input  string
+		err    error
+		offset int
+	}{
+		{"", ErrEmptyPayload, 0},
+		{"foo|c", ErrNoValSep, 3},
+		{"foo", ErrNoValSep, 3},
+		{"foo:1", ErrNoTypeSep, 5},
+		{"foo:1|", ErrInvalidTrailingPipe, 5},
+		{"foo:1|x", ErrInvalidMetricType, 6},
+		{"foo:1|@0.5|c", ErrInvalidMetricType, 6},
+		{"foo:1|c|@2", ErrInvalidSampleRate, 8},
+		{"foo:abc|c", ErrInvalidValue, 4},
+		{"foo:1|c:2|ms", ErrMultipleMetrics, 8},
+	} {
+		m, err := s.p.Parse([]byte(tc.input))
+		s.Nil(m, tc.input)
+		s.Require().IsType(&ParseError{}, err, tc.input)
+		s.True(errors.Is(err, tc.err), tc.input)
+		s.Equal(tc.offset, err.(*ParseError).Offset, tc.input)
+	}
+
+	var v DatadogMetricView
+	s.True(errors.Is(s.p.ParseView(&v, []byte("foo:1|c:2|ms")), ErrMultipleMetrics))
+	var dst DatadogMetric
+	s.True(errors.Is(s.p.ParseInto(&dst, []byte("_sc|foo|0")), ErrNoValSep))
+}
+
+func (s *StatsDSuite) Test_ParseInto() {
+	var dst DatadogMetric
+	s.Require().NoError(s.p.ParseInto(&dst, []byte("foo:1.5|h|@0.5")))
+	s.Equal("foo", dst.Name)
+	s.Equal(MetricHist, dst.Type)
+	s.Equal(1.5, dst.FloatValue)
+	s.Equal(0.5, dst.SampleRate)
+}
+
+func (s *StatsDSuite) Test_ParseMulti() {
+	ms, errs := s.p.ParseMulti([]byte("foo:1|c:2|ms|@0.5:x|c:3|g\nbar:4|c"))
+	s.Require().Len(ms, 5)
+	s.Require().Len(errs, 5)
+
+	s.Nil(errs[0])
+	s.Equal("foo", ms[0].Name)
+	s.Equal(MetricCount, ms[0].Type)
+	s.Equal(1.0, ms[0].FloatValue)
+
+	s.Nil(errs[1])
+	s.Equal("foo", ms[1].Name)
+	s.Equal(MetricTiming, ms[1].Type)
+	s.Equal(0.5, ms[1].SampleRate)
+
+	s.Nil(ms[2])
+	s.Require().IsType(&ParseError{}, errs[2])
+	s.True(errors.Is(errs[2], ErrInvalidValue))
+	s.Equal(1, errs[2].(*ParseError).Line)
+	s.Equal(18, errs[2].(*ParseError).Offset)
+
+	s.Nil(errs[3])
+	s.Equal(MetricGauge, ms[3].Type)
+
+	s.Nil(errs[4])
+	s.Equal("bar", ms[4].Name)
+}
+
+func (s *StatsDSuite) Test_ParseEach_Stop() {
+	var names []string
+	s.p.ParseEach([]byte("foo:1|c:2|c\nbar:1|c"), func(m *DatadogMetric, err *ParseError) bool {
+		names = append(names, m.Name)
+		return false
+	})
+	s.Equal([]string{"foo"}, names)
+}
+
+func TestStatsDSuite(t *testing.T) {
+	suite.Run(t, new(StatsDSuite))
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+)
+
+// Tag is a single DataDog tag, e.g. `env:prod` or `canary`.
+type Tag struct {
+	Key   string
+	Value string
+	// HasValue distinguishes a tag with an empty value, e.g. `env:`, from a tag without a value, e.g. `env`.
+	HasValue bool
+}
+
+// ParseTag parses a raw tag. The key is everything before the first colon, and the value is everything after it.
+func ParseTag(raw string) Tag {
+	idx := strings.Index(raw, ":")
+	if idx == -1 {
+		return Tag{Key: raw}
+	}
+	return Tag{
+		Key:      raw[:idx],
+		Value:    raw[idx+1:],
+		HasValue: true,
+	}
+}
+
+// String returns the raw form of a Tag.
+func (t Tag) String() string {
+	if !t.HasValue {
+		return t.Key
+	}
+	return t.Key + ":" + t.Value
+}
+
+// ParsedTags returns the tags of a Datadog metric as structured Tags.
+func (d *DatadogMetric) ParsedTags() []Tag {
+	tags := make([]Tag, 0, len(d.Tags))
+	for _, raw := range d.Tags {
+		tags = append(tags, ParseTag(raw))
+	}
+	return tags
+}
+
+// TagValue returns the value of the first tag of a Datadog metric with the given key.
+// The second return value is false if there is no such tag, or if the tag has no value.
+func (d *DatadogMetric) TagValue(key string) (string, bool) {
+	for _, raw := range d.Tags {
+		t := ParseTag(raw)
+		if t.Key == key && t.HasValue {
+			return t.Value, true
+		}
+	}
+	return "", false
+}
+
+// TagValues returns the values of all tags of a Datadog metric with the given key, in order.
+func (d *DatadogMetric) TagValues(key string) []string {
+	var values []string
+	for _, raw := range d.Tags {
+		t := ParseTag(raw)
+		if t.Key == key && t.HasValue {
+			values = append(values, t.Value)
+		}
+	}
+	return values
+}
+
+// HasTag returns true if a Datadog metric has a tag with the given key, with or without a value.
+func (d *DatadogMetric) HasTag(key string) bool {
+	for _, raw := range d.Tags {
+		if ParseTag(raw).Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalTags returns the tags of a Datadog metric sorted and with duplicates removed.
+// The tags of the metric are not modified.
+func (d *DatadogMetric) CanonicalTags() []string {
+	tags := make([]string, len(d.Tags))
+	copy(tags, d.Tags)
+	sort.Strings(tags)
+
+	deduped := tags[:0]
+	for i := range tags {
+		if i > 0 && tags[i] == tags[i-1] {
+			continue
+		}
+		deduped = append(deduped, tags[i])
+	}
+	return deduped
+}
+
+// ContextKey returns a key identifying the context of a Datadog metric: its name, type and canonical tags.
+// Metrics which only differ in value or in the order or repetition of their tags have the same context key,
+// so it is suitable for use as a map key when aggregating metrics.
+func (d *DatadogMetric) ContextKey() string {
+	return d.Name + "|" + string(d.Type) + "|" + strings.Join(d.CanonicalTags(), ",")
+}
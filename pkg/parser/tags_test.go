@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TagSuite struct {
+	suite.Suite
+}
+
+func (s *TagSuite) Test_ParseTag() {
+	s.Equal(Tag{Key: "env", Value: "prod", HasValue: true}, ParseTag("env:prod"))
+	s.Equal(Tag{Key: "url", Value: "http://foo:8080", HasValue: true}, ParseTag("url:http://foo:8080"))
+	s.Equal(Tag{Key: "env", Value: "", HasValue: true}, ParseTag("env:"))
+	s.Equal(Tag{Key: "canary"}, ParseTag("canary"))
+	s.Equal(Tag{}, ParseTag(""))
+}
+
+func (s *TagSuite) Test_Tag_String() {
+	for _, raw := range []string{"env:prod", "url:http://foo:8080", "env:", "canary", ""} {
+		s.Equal(raw, ParseTag(raw).String())
+	}
+}
+
+func (s *TagSuite) Test_ParsedTags() {
+	m := &DatadogMetric{Tags: []string{"env:prod", "canary"}}
+	s.Equal([]Tag{
+		{Key: "env", Value: "prod", HasValue: true},
+		{Key: "canary"},
+	}, m.ParsedTags())
+}
+
+func (s *TagSuite) Test_TagValue() {
+	m := &DatadogMetric{Tags: []string{"canary", "role:web", "role:api", "url:http://foo:8080", "empty:"}}
+
+	v, ok := m.TagValue("role")
+	s.True(ok)
+	s.Equal("web", v)
+
+	v, ok = m.TagValue("url")
+	s.True(ok)
+	s.Equal("http://foo:8080", v)
+
+	v, ok = m.TagValue("empty")
+	s.True(ok)
+	s.Equal("", v)
+
+	v, ok = m.TagValue("canary")
+	s.False(ok)
+	s.Equal("", v)
+
+	v, ok = m.TagValue("missing")
+	s.False(ok)
+	s.Equal("", v)
+
+	s.Equal([]string{"web", "api"}, m.TagValues("role"))
+	s.Nil(m.TagValues("canary"))
+}
+
+func (s *TagSuite) Test_HasTag() {
+	m := &DatadogMetric{Tags: []string{"canary", "role:web"}}
+	s.True(m.HasTag("canary"))
+	s.True(m.HasTag("role"))
+	s.False(m.HasTag("web"))
+	s.False(m.HasTag("missing"))
+}
+
+func (s *TagSuite) Test_CanonicalTags() {
+	m := &DatadogMetric{Tags: []string{"role:web", "env:prod", "canary", "env:prod"}}
+	s.Equal([]string{"canary", "env:prod", "role:web"}, m.CanonicalTags())
+	// the original tags are left untouched
+	s.Equal([]string{"role:web", "env:prod", "canary", "env:prod"}, m.Tags)
+
+	s.Empty((&DatadogMetric{}).CanonicalTags())
+}
+
+func (s *TagSuite) Test_ContextKey() {
+	a := &DatadogMetric{Name: "foo", Type: MetricCount, Value: "1", Tags: []string{"b", "a"}}
+	b := &DatadogMetric{Name: "foo", Type: MetricCount, Value: "2", Tags: []string{"a", "b", "a"}}
+	c := &DatadogMetric{Name: "foo", Type: MetricGauge, Value: "1", Tags: []string{"a", "b"}}
+
+	s.Equal("foo|C|a,b", a.ContextKey())
+	s.Equal(a.ContextKey(), b.ContextKey())
+	s.NotEqual(a.ContextKey(), c.ContextKey())
+}
+
+func TestTagSuite(t *testing.T) {
+	suite.Run(t, new(TagSuite))
+}
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"time"
+)
+
+// DatadogMetricView is a single DataDog metric whose byte slices alias the payload it was parsed from,
+// so that parsing it does not allocate. A DatadogMetricView is only valid until its payload is modified,
+// e.g. when a read buffer is reused, and cannot hold events or service checks.
+type DatadogMetricView struct {
+	Name []byte
+	// Value is the first value of the metric.
+	Value []byte
+	// RawValues holds every value of the metric separated by colons, e.g. `1.2:3.4` for a packed message.
+	RawValues []byte
+	// FloatValue is Value parsed as a number. It is zero for sets.
+	FloatValue float64
+	// Relative is true if the values of a gauge are signed deltas, e.g. `+5` or `-3`.
+	Relative bool
+	Type     MetricType
+	// RawTags holds the comma-separated tags of the metric, excluding the leading '#'.
+	RawTags []byte
+	// NameTags holds the tags embedded in the metric name if the parser has a tag dialect, e.g. `tag1=val1,tag2=val2`.
+	NameTags     []byte
+	SampleRate   float64
+	Timestamp    time.Time
+	ContainerID  []byte
+	ExternalData []byte
+	Cardinality  []byte
+
+	// nameTagSep separates the tags in NameTags.
+	nameTagSep []byte
+}
+
+// AppendValues appends every value of a view to dst and returns the extended slice.
+func (v *DatadogMetricView) AppendValues(dst [][]byte) [][]byte {
+	for rest := v.RawValues; rest != nil; {
+		var raw []byte
+		raw, rest = nextToken(rest, sepColon)
+		dst = append(dst, raw)
+	}
+	return dst
+}
+
+// AppendFloatValues appends every value of a view parsed as a number to dst and returns the extended slice.
+// Sets have no numeric values, so dst is returned unchanged for sets.
+func (v *DatadogMetricView) AppendFloatValues(dst []float64) []float64 {
+	if v.Type == MetricSet {
+		return dst
+	}
+	for rest := v.RawValues; rest != nil; {
+		var raw []byte
+		raw, rest = nextToken(rest, sepColon)
+		// values were validated while parsing
+		f, _ := parseFloat(raw)
+		dst = append(dst, f)
+	}
+	return dst
+}
+
+// AppendTags appends every non-empty tag of a view to dst and returns the extended slice.
+// Tags embedded in the metric name come first, in their original `key=value` form.
+func (v *DatadogMetricView) AppendTags(dst [][]byte) [][]byte {
+	if len(v.NameTags) > 0 {
+		for rest := v.NameTags; rest != nil; {
+			var raw []byte
+			raw, rest = nextToken(rest, v.nameTagSep)
+			if len(raw) == 0 {
+				continue
+			}
+			dst = append(dst, raw)
+		}
+	}
+	if len(v.RawTags) == 0 {
+		return dst
+	}
+	for rest := v.RawTags; rest != nil; {
+		var raw []byte
+		raw, rest = nextToken(rest, sepComma)
+		if len(raw) == 0 {
+			continue
+		}
+		dst = append(dst, raw)
+	}
+	return dst
+}
+
+// copyInto copies a view into dst, reusing the slices and unchanged strings of dst.
+func (v *DatadogMetricView) copyInto(dst *DatadogMetric) {
+	dst.Name = reuseString(dst.Name, v.Name)
+	dst.Type = v.Type
+	dst.SampleRate = v.SampleRate
+	dst.Timestamp = v.Timestamp
+	dst.Relative = v.Relative
+	dst.FloatValue = v.FloatValue
+	dst.ContainerID = reuseString(dst.ContainerID, v.ContainerID)
+	dst.ExternalData = reuseString(dst.ExternalData, v.ExternalData)
+	dst.Cardinality = reuseString(dst.Cardinality, v.Cardinality)
+	dst.ServiceCheck = nil
+	dst.Event = nil
+
+	dst.Values = dst.Values[:0]
+	for rest := v.RawValues; rest != nil; {
+		var raw []byte
+		raw, rest = nextToken(rest, sepColon)
+		dst.Values = appendString(dst.Values, raw)
+	}
+	dst.Value = dst.Values[0]
+	dst.FloatValues = v.AppendFloatValues(dst.FloatValues[:0])
+
+	dst.Tags = dst.Tags[:0]
+	if len(v.NameTags) > 0 {
+		// tags are converted to DogStatsD form on the stack, so that unchanged tags are not reallocated
+		var buf [128]byte
+		for rest := v.NameTags; rest != nil; {
+			var raw []byte
+			raw, rest = nextToken(rest, v.nameTagSep)
+			if len(raw) == 0 {
+				continue
+			}
+			dst.Tags = appendString(dst.Tags, appendNameTag(buf[:0], raw))
+		}
+	}
+	if len(v.RawTags) > 0 {
+		for rest := v.RawTags; rest != nil; {
+			var raw []byte
+			raw, rest = nextToken(rest, sepComma)
+			if len(raw) == 0 {
+				continue
+			}
+			dst.Tags = appendString(dst.Tags, raw)
+		}
+	}
+}
+
+// reuseString returns s if it is equal to b, or b converted to a string otherwise.
+func reuseString(s string, b []byte) string {
+	// comparing against a converted byte slice does not allocate
+	if s == string(b) {
+		return s
+	}
+	return string(b)
+}
+
+// appendString appends b to dst as a string, reusing the string in the spare capacity of dst if it is equal to b.
+func appendString(dst []string, b []byte) []string {
+	if n := len(dst); n < cap(dst) {
+		dst = dst[:n+1]
+		dst[n] = reuseString(dst[n], b)
+		return dst
+	}
+	return append(dst, string(b))
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DatadogMetricViewSuite struct {
+	suite.Suite
+}
+
+func (s *DatadogMetricViewSuite) Test_AppendValues() {
+	v := &DatadogMetricView{RawValues: []byte("1.2:3.4:5.6")}
+	s.Equal([][]byte{[]byte("1.2"), []byte("3.4"), []byte("5.6")}, v.AppendValues(nil))
+
+	v = &DatadogMetricView{RawValues: []byte("1")}
+	s.Equal([][]byte{[]byte("0"), []byte("1")}, v.AppendValues([][]byte{[]byte("0")}))
+}
+
+func (s *DatadogMetricViewSuite) Test_AppendFloatValues() {
+	v := &DatadogMetricView{Type: MetricHist, RawValues: []byte("1.2:3.4:-5.6")}
+	s.Equal([]float64{1.2, 3.4, -5.6}, v.AppendFloatValues(nil))
+
+	v = &DatadogMetricView{Type: MetricSet, RawValues: []byte("alice")}
+	s.Nil(v.AppendFloatValues(nil))
+}
+
+func (s *DatadogMetricViewSuite) Test_AppendTags() {
+	v := &DatadogMetricView{RawTags: []byte("env:dev,team:a")}
+	s.Equal([][]byte{[]byte("env:dev"), []byte("team:a")}, v.AppendTags(nil))
+
+	v = &DatadogMetricView{}
+	s.Nil(v.AppendTags(nil))
+}
+
+func (s *DatadogMetricViewSuite) Test_copyInto() {
+	v := &DatadogMetricView{
+		Name:       []byte("foo"),
+		Value:      []byte("1"),
+		RawValues:  []byte("1:2"),
+		FloatValue: 1,
+		Type:       MetricHist,
+		RawTags:    []byte("env:dev"),
+		SampleRate: 1,
+	}
+	m := &DatadogMetric{
+		Name:         "bar",
+		Values:       []string{"1", "5", "6"},
+		Tags:         []string{"env:prod", "team:a"},
+		ContainerID:  "abc123",
+		ServiceCheck: &DatadogServiceCheck{},
+	}
+	v.copyInto(m)
+	s.EqualValues(&DatadogMetric{
+		Name:        "foo",
+		Value:       "1",
+		Values:      []string{"1", "2"},
+		FloatValue:  1,
+		FloatValues: []float64{1, 2},
+		Type:        MetricHist,
+		Tags:        []string{"env:dev"},
+		SampleRate:  1,
+	}, m)
+}
+
+func (s *DatadogMetricViewSuite) Test_appendString() {
+	dst := make([]string, 0, 2)
+	dst = appendString(dst, []byte("foo"))
+	s.Equal([]string{"foo"}, dst)
+
+	dst = appendString(dst[:0], []byte("foo"))
+	dst = appendString(dst, []byte("bar"))
+	dst = appendString(dst, []byte("baz"))
+	s.Equal([]string{"foo", "bar", "baz"}, dst)
+}
+
+func TestDatadogMetricViewSuite(t *testing.T) {
+	suite.Run(t, new(DatadogMetricViewSuite))
+}
@@ -0,0 +1,293 @@
+// Package server provides a fake DogStatsD agent which parses the metrics it receives and passes them to a Handler.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/johnstcn/fakeadog/pkg/parser"
+)
+
+// DefaultAddr is the address a Server listens on if none is configured.
+const DefaultAddr = "localhost:8125"
+
+// DefaultBufferSize is the size of the buffer each packet is read into, from datadog-go/statsd.
+const DefaultBufferSize = 65467
+
+// ErrServerClosed is returned by ListenAndServe and Serve after a call to Close.
+var ErrServerClosed = fmt.Errorf("server closed")
+
+// ErrNoHandler is returned by ListenAndServe and Serve if the Config has no Handler.
+var ErrNoHandler = fmt.Errorf("no handler configured")
+
+// ErrNotListening is returned by Serve if Listen has not been called.
+var ErrNotListening = fmt.Errorf("server is not listening")
+
+// ErrUnsupportedNetwork is returned by Listen if the Config has a Network other than udp, udp4, udp6, unixgram, unix, tcp, tcp4 or tcp6.
+var ErrUnsupportedNetwork = fmt.Errorf("unsupported network")
+
+// Handler handles the results of parsing each line received by a Server.
+// Exactly one of m and err is non-nil. A Handler of a Server listening on a stream socket, or shared by several Servers,
+// must be safe to call concurrently.
+type Handler interface {
+	Handle(m *parser.DatadogMetric, err *parser.ParseError)
+}
+
+// HandlerFunc adapts an ordinary function to a Handler.
+type HandlerFunc func(m *parser.DatadogMetric, err *parser.ParseError)
+
+// Handle calls f(m, err).
+func (f HandlerFunc) Handle(m *parser.DatadogMetric, err *parser.ParseError) {
+	f(m, err)
+}
+
+// Config configures a Server.
+type Config struct {
+	// Network is the network to listen on: "udp", "udp4", "udp6", "unixgram", "unix", "tcp", "tcp4" or "tcp6".
+	// On "unix" every packet is expected to be prefixed with its length, as sent by datadog-go in stream mode,
+	// and on "tcp" every line is parsed as it is received. Defaults to "udp".
+	Network string
+	// Addr is the address to listen on, e.g. "localhost:8125" for UDP and TCP, where port 0 picks a free port,
+	// or a socket path such as "/var/run/datadog/dsd.socket" for unixgram and unix. Defaults to DefaultAddr.
+	Addr string
+	// SocketMode is the permissions of the socket file when listening on unixgram or unix. Defaults to DefaultSocketMode.
+	SocketMode os.FileMode
+	// Parser parses each packet received. Defaults to parser.NewDatadogParser().
+	Parser parser.DatadogParser
+	// Handler is called with every metric received and every line which fails to parse.
+	Handler Handler
+	// OnError is called with errors which are not specific to a single line, such as read errors
+	// and panics recovered while handling a packet. Defaults to ignoring them.
+	OnError func(err error)
+	// BufferSize is the maximum size of a packet in bytes. Defaults to DefaultBufferSize.
+	BufferSize int
+	// OnConnClose is called with the stats of each connection to a stream socket once it is closed. Defaults to ignoring them.
+	OnConnClose func(stats ConnStats)
+	// IdleTimeout closes connections to a stream socket which send nothing for this long. Defaults to no timeout.
+	IdleTimeout time.Duration
+	// MaxConns is the maximum number of connections to a stream socket open at once.
+	// Further connections are closed as soon as they are accepted. Defaults to no limit.
+	MaxConns int
+	// EOLRequired drops data left after the last newline when a TCP connection is closed, as the agent does with
+	// dogstatsd_eol_required. By default it is parsed as a final line.
+	EOLRequired bool
+}
+
+// Server is a fake DogStatsD agent.
+type Server struct {
+	cfg Config
+
+	mu     sync.Mutex
+	conn   net.PacketConn
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	nextID uint64
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// New returns a new Server configured by cfg.
+func New(cfg Config) *Server {
+	if cfg.Network == "" {
+		cfg.Network = "udp"
+	}
+	if cfg.SocketMode == 0 {
+		cfg.SocketMode = DefaultSocketMode
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultAddr
+	}
+	if cfg.Parser == nil {
+		cfg.Parser = parser.NewDatadogParser()
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+	if cfg.OnConnClose == nil {
+		cfg.OnConnClose = func(ConnStats) {}
+	}
+	return &Server{
+		cfg:   cfg,
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen binds the address of the server without serving it, so that Addr can be called before Serve.
+// A stale unix socket left behind by a previous process is removed first.
+// Calling Listen more than once has no effect.
+func (s *Server) Listen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	if s.conn != nil || s.ln != nil {
+		return nil
+	}
+
+	var err error
+	switch s.cfg.Network {
+	case "udp", "udp4", "udp6":
+		s.conn, err = net.ListenPacket(s.cfg.Network, s.cfg.Addr)
+	case "unixgram":
+		s.conn, err = listenUnixgram(s.cfg.Addr, s.cfg.SocketMode)
+	case "unix":
+		s.ln, err = listenUnix(s.cfg.Addr, s.cfg.SocketMode)
+	case "tcp", "tcp4", "tcp6":
+		s.ln, err = net.Listen(s.cfg.Network, s.cfg.Addr)
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnsupportedNetwork, s.cfg.Network)
+	}
+	if err != nil {
+		return fmt.Errorf("listening on %s %s: %w", s.cfg.Network, s.cfg.Addr, err)
+	}
+	return nil
+}
+
+// Addr returns the address the server is listening on, or nil if it is not listening.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.conn != nil:
+		return s.conn.LocalAddr()
+	case s.ln != nil:
+		return s.ln.Addr()
+	}
+	return nil
+}
+
+// ListenAndServe listens on the configured address and serves it until ctx is done or Close is called.
+// Returns nil if ctx is done, and ErrServerClosed after a call to Close.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	return s.Serve(ctx)
+}
+
+// Serve reads packets until ctx is done or Close is called, passing the result of parsing each line to the handler.
+// On a stream socket each connection is served concurrently, and Serve waits for them all to be closed before returning.
+// Listen must have been called first. Returns nil if ctx is done, and ErrServerClosed after a call to Close.
+func (s *Server) Serve(ctx context.Context) error {
+	if s.cfg.Handler == nil {
+		return ErrNoHandler
+	}
+
+	s.mu.Lock()
+	conn, ln := s.conn, s.ln
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return ErrServerClosed
+	}
+	if conn == nil && ln == nil {
+		return ErrNotListening
+	}
+
+	// unblock reads once ctx is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Close()
+		case <-stop:
+		}
+	}()
+
+	switch {
+	case ln != nil && s.cfg.Network == "unix":
+		s.serveStream(ln, readLengthPrefixed)
+	case ln != nil:
+		s.serveStream(ln, readLines(s.cfg.EOLRequired))
+	default:
+		s.servePackets(conn)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return ErrServerClosed
+}
+
+// servePackets reads packets from conn until the server is closed.
+func (s *Server) servePackets(conn net.PacketConn) {
+	buf := make([]byte, s.cfg.BufferSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			s.cfg.OnError(fmt.Errorf("reading from %s: %w", conn.LocalAddr(), err))
+			continue
+		}
+		s.handlePacket(buf[:n])
+	}
+}
+
+// Close stops the server from listening and closes every open connection,
+// removing its socket file if listening on a unix socket. Any blocked Serve call returns ErrServerClosed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+
+	var err error
+	switch {
+	case s.conn != nil:
+		err = s.conn.Close()
+	case s.ln != nil:
+		err = s.ln.Close()
+	default:
+		return nil
+	}
+	if s.cfg.Network == "unixgram" || s.cfg.Network == "unix" {
+		if rmErr := os.Remove(s.cfg.Addr); rmErr != nil && err == nil && !os.IsNotExist(rmErr) {
+			err = rmErr
+		}
+	}
+	return err
+}
+
+// isClosed returns true if Close has been called.
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// handlePacket passes the result of parsing every line of payload to the handler,
+// returning the number of metrics parsed and the number of lines which failed to parse.
+// A panic while handling payload is reported to OnError rather than allowed to stop the server.
+func (s *Server) handlePacket(payload []byte) (metrics, errs int) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.cfg.OnError(fmt.Errorf("recovered from panic handling payload %q: %v", payload, r))
+		}
+	}()
+
+	s.cfg.Parser.ParseEach(payload, func(m *parser.DatadogMetric, err *parser.ParseError) bool {
+		if err != nil {
+			errs++
+		} else {
+			metrics++
+		}
+		s.cfg.Handler.Handle(m, err)
+		return true
+	})
+	return metrics, errs
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/johnstcn/fakeadog/pkg/parser"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// recorder is a Handler which records every metric and error it is called with.
+type recorder struct {
+	mu      sync.Mutex
+	metrics []*parser.DatadogMetric
+	errs    []*parser.ParseError
+	notify  chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		notify: make(chan struct{}, 100),
+	}
+}
+
+// Handle implements Handler.
+func (r *recorder) Handle(m *parser.DatadogMetric, err *parser.ParseError) {
+	r.mu.Lock()
+	if m != nil {
+		r.metrics = append(r.metrics, m)
+	}
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+	r.mu.Unlock()
+	r.notify <- struct{}{}
+}
+
+// wait waits for the handler to be called n times.
+func (r *recorder) wait(n int) bool {
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.notify:
+		case <-time.After(5 * time.Second):
+			return false
+		}
+	}
+	return true
+}
+
+type ServerSuite struct {
+	suite.Suite
+}
+
+// start starts a server on a free port, returning it and a channel receiving the result of ListenAndServe.
+func (s *ServerSuite) start(ctx context.Context, cfg Config) (*Server, chan error) {
+	cfg.Addr = "127.0.0.1:0"
+	srv := New(cfg)
+	s.Require().NoError(srv.Listen())
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ListenAndServe(ctx)
+	}()
+	return srv, done
+}
+
+// send sends payload to the UDP address addr.
+func (s *ServerSuite) send(addr net.Addr, payload string) {
+	conn, err := net.Dial("udp", addr.String())
+	s.Require().NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(payload))
+	s.Require().NoError(err)
+}
+
+func (s *ServerSuite) Test_ListenAndServe() {
+	rec := newRecorder()
+	srv, done := s.start(context.Background(), Config{Handler: rec})
+	defer srv.Close()
+
+	s.send(srv.Addr(), "foo:1|c|#env:dev\nfoo:1|x\nbar:2|g")
+	s.Require().True(rec.wait(3))
+
+	rec.mu.Lock()
+	s.Require().Len(rec.metrics, 2)
+	s.Equal("foo", rec.metrics[0].Name)
+	s.Equal([]string{"env:dev"}, rec.metrics[0].Tags)
+	s.Equal("bar", rec.metrics[1].Name)
+	s.Require().Len(rec.errs, 1)
+	s.Equal(2, rec.errs[0].Line)
+	s.True(errors.Is(rec.errs[0], parser.ErrInvalidMetricType))
+	rec.mu.Unlock()
+
+	s.NoError(srv.Close())
+	s.True(errors.Is(<-done, ErrServerClosed))
+}
+
+func (s *ServerSuite) Test_ContextCancel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	srv, done := s.start(ctx, Config{Handler: newRecorder()})
+	cancel()
+	select {
+	case err := <-done:
+		s.NoError(err)
+	case <-time.After(5 * time.Second):
+		s.Fail("server did not stop")
+	}
+	s.NoError(srv.Close())
+}
+
+func (s *ServerSuite) Test_Parser() {
+	rec := newRecorder()
+	p := parser.NewDatadogParserWithOptions(parser.DatadogParserOptions{Protocol: parser.ProtocolStatsD})
+	srv, _ := s.start(context.Background(), Config{Handler: rec, Parser: p})
+	defer srv.Close()
+
+	s.send(srv.Addr(), "foo:1|c:2|ms")
+	s.Require().True(rec.wait(2))
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	s.Require().Len(rec.metrics, 2)
+	s.Equal(parser.MetricTiming, rec.metrics[1].Type)
+}
+
+func (s *ServerSuite) Test_HandlerPanic() {
+	rec := newRecorder()
+	errs := make(chan error, 1)
+	handler := HandlerFunc(func(m *parser.DatadogMetric, err *parser.ParseError) {
+		if m != nil && m.Name == "panic" {
+			panic("boom")
+		}
+		rec.Handle(m, err)
+	})
+	srv, _ := s.start(context.Background(), Config{
+		Handler: handler,
+		OnError: func(err error) {
+			errs <- err
+		},
+	})
+	defer srv.Close()
+
+	s.send(srv.Addr(), "panic:1|c")
+	select {
+	case err := <-errs:
+		s.Contains(err.Error(), "boom")
+	case <-time.After(5 * time.Second):
+		s.Fail("panic was not reported")
+	}
+
+	// the server keeps serving after a panic
+	s.send(srv.Addr(), "foo:1|c")
+	s.Require().True(rec.wait(1))
+}
+
+func (s *ServerSuite) Test_Errors() {
+	srv := New(Config{Addr: "127.0.0.1:0"})
+	s.Nil(srv.Addr())
+	s.True(errors.Is(srv.Serve(context.Background()), ErrNoHandler))
+
+	srv = New(Config{Addr: "127.0.0.1:0", Handler: newRecorder()})
+	s.True(errors.Is(srv.Serve(context.Background()), ErrNotListening))
+
+	s.NoError(srv.Close())
+	s.True(errors.Is(srv.ListenAndServe(context.Background()), ErrServerClosed))
+
+	srv = New(Config{Addr: "not an address", Handler: newRecorder()})
+	s.Error(srv.ListenAndServe(context.Background()))
+}
+
+func (s *ServerSuite) Test_New_Defaults() {
+	srv := New(Config{})
+	s.Equal(DefaultAddr, srv.cfg.Addr)
+	s.Equal(DefaultBufferSize, srv.cfg.BufferSize)
+	s.NotNil(srv.cfg.Parser)
+	s.NotNil(srv.cfg.OnError)
+}
+
+func TestServerSuite(t *testing.T) {
+	suite.Run(t, new(ServerSuite))
+}
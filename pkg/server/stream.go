@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// ErrFrameTooLarge is reported to OnError for each frame larger than the BufferSize, which is dropped.
+var ErrFrameTooLarge = fmt.Errorf("frame too large")
+
+// ErrTruncatedFrame is reported to OnError when a connection is closed part way through a frame.
+var ErrTruncatedFrame = fmt.Errorf("truncated frame")
+
+// ErrTooManyConns is reported to OnError for each connection closed because MaxConns connections are already open.
+var ErrTooManyConns = fmt.Errorf("too many connections")
+
+// ConnStats are the stats of a single connection to a stream socket.
+type ConnStats struct {
+	// ID identifies the connection among those accepted by the same Server, starting from 1.
+	ID uint64
+	// Remote is the address of the client, which is usually empty for unix sockets.
+	Remote string
+	// Opened is when the connection was accepted.
+	Opened time.Time
+	// Duration is how long the connection was open for.
+	Duration time.Duration
+	// Frames is the number of frames read and parsed, which are lines on TCP.
+	Frames int
+	// Bytes is the number of bytes of the frames read and parsed, excluding framing.
+	Bytes int
+	// Metrics is the number of metrics parsed.
+	Metrics int
+	// ParseErrors is the number of lines which failed to parse.
+	ParseErrors int
+	// Dropped is the number of frames dropped because they were too large, truncated or unterminated.
+	Dropped int
+	// TimedOut is true if the connection was closed because it was idle for longer than the IdleTimeout.
+	TimedOut bool
+}
+
+// frameReader reads the next frame from r, using buf to hold it if it is not already buffered by r.
+// Returns io.EOF if the connection was closed between frames, ErrFrameTooLarge if the frame was dropped
+// and reading can carry on, and any other error if the connection can no longer be read.
+type frameReader func(r *bufio.Reader, buf []byte) ([]byte, error)
+
+// readLengthPrefixed reads a frame prefixed with its length as a 4-byte little-endian integer, as sent by
+// datadog-go to unix stream sockets.
+func readLengthPrefixed(r *bufio.Reader, buf []byte) ([]byte, error) {
+	var header [4]byte
+	if read, err := io.ReadFull(r, header[:]); err != nil {
+		if read > 0 {
+			return nil, fmt.Errorf("%w: reading length: %w", ErrTruncatedFrame, err)
+		}
+		return nil, err
+	}
+
+	n := int(binary.LittleEndian.Uint32(header[:]))
+	if n > len(buf) {
+		if discarded, err := r.Discard(n); err != nil {
+			return nil, fmt.Errorf("%w: %d of %d bytes: %w", ErrTruncatedFrame, discarded, n, err)
+		}
+		return nil, fmt.Errorf("%w: %d bytes exceeds buffer size of %d", ErrFrameTooLarge, n, len(buf))
+	}
+	if read, err := io.ReadFull(r, buf[:n]); err != nil {
+		return nil, fmt.Errorf("%w: %d of %d bytes: %w", ErrTruncatedFrame, read, n, err)
+	}
+	return buf[:n], nil
+}
+
+// serveStream accepts connections from ln until the server is closed, serving each with next in its own goroutine.
+// Waits for every connection to be closed before returning.
+func (s *Server) serveStream(ln net.Listener, next frameReader) {
+	defer s.wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			s.cfg.OnError(fmt.Errorf("accepting on %s: %w", ln.Addr(), err))
+			// avoid spinning on errors such as running out of file descriptors
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+
+		id, err := s.track(conn)
+		if err == ErrServerClosed {
+			conn.Close()
+			return
+		}
+		if err != nil {
+			s.cfg.OnError(fmt.Errorf("rejecting connection from %s on %s: %w", conn.RemoteAddr(), ln.Addr(), err))
+			conn.Close()
+			continue
+		}
+		go s.serveConn(conn, id, next)
+	}
+}
+
+// track records conn as open so that Close can close it, returning its ID.
+// Returns ErrServerClosed if the server has been closed, and ErrTooManyConns if MaxConns connections are already open.
+func (s *Server) track(conn net.Conn) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrServerClosed
+	}
+	if s.cfg.MaxConns > 0 && len(s.conns) >= s.cfg.MaxConns {
+		return 0, ErrTooManyConns
+	}
+	s.nextID++
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return s.nextID, nil
+}
+
+// untrack records conn as closed, making room for another connection.
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// serveConn reads frames from conn with next until it is closed, then reports its stats to OnConnClose.
+func (s *Server) serveConn(conn net.Conn, id uint64, next frameReader) {
+	defer s.wg.Done()
+
+	stats := ConnStats{
+		ID:     id,
+		Opened: time.Now(),
+	}
+	if addr := conn.RemoteAddr(); addr != nil {
+		stats.Remote = addr.String()
+	}
+
+	r := bufio.NewReader(conn)
+	buf := make([]byte, s.cfg.BufferSize)
+	for {
+		if s.cfg.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.cfg.IdleTimeout))
+		}
+		frame, err := next(r, buf)
+		if err == nil {
+			metrics, errs := s.handlePacket(frame)
+			stats.Frames++
+			stats.Bytes += len(frame)
+			stats.Metrics += metrics
+			stats.ParseErrors += errs
+			continue
+		}
+
+		if errors.Is(err, ErrFrameTooLarge) || errors.Is(err, ErrTruncatedFrame) || errors.Is(err, ErrUnterminatedLine) {
+			stats.Dropped++
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			stats.TimedOut = true
+		}
+		// errors caused by Close, the client hanging up or the idle timeout are expected
+		if err != io.EOF && !stats.TimedOut && !s.isClosed() {
+			s.cfg.OnError(fmt.Errorf("reading from connection %d on %s: %w", id, conn.LocalAddr(), err))
+		}
+		if !errors.Is(err, ErrFrameTooLarge) {
+			break
+		}
+	}
+
+	conn.Close()
+	s.untrack(conn)
+	stats.Duration = time.Since(stats.Opened)
+	s.cfg.OnConnClose(stats)
+}
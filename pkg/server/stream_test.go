@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// frame returns payload prefixed with its length.
+func frame(payload string) []byte {
+	b := make([]byte, 4, 4+len(payload))
+	binary.LittleEndian.PutUint32(b, uint32(len(payload)))
+	return append(b, payload...)
+}
+
+type StreamSuite struct {
+	suite.Suite
+	path string
+
+	mu    sync.Mutex
+	stats []ConnStats
+	errs  []error
+	// closed receives the ID of each connection once it is closed.
+	closed chan uint64
+	srv    *Server
+	// stopped is closed once Serve has returned.
+	stopped chan struct{}
+}
+
+func (s *StreamSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "dsd.socket")
+	s.stats = nil
+	s.errs = nil
+	s.closed = make(chan uint64, 100)
+}
+
+// TearDownTest stops the server, so that it cannot call back into the next test.
+func (s *StreamSuite) TearDownTest() {
+	if s.srv != nil {
+		s.srv.Close()
+		<-s.stopped
+		s.srv = nil
+	}
+}
+
+// start starts a server on a unix stream socket.
+func (s *StreamSuite) start(rec *recorder, bufferSize int) (*Server, chan error) {
+	return s.startConfig(Config{
+		Network:    "unix",
+		Addr:       s.path,
+		Handler:    rec,
+		BufferSize: bufferSize,
+	})
+}
+
+// startConfig starts a server configured by cfg, recording its errors and connection stats.
+func (s *StreamSuite) startConfig(cfg Config) (*Server, chan error) {
+	cfg.OnError = func(err error) {
+		s.mu.Lock()
+		s.errs = append(s.errs, err)
+		s.mu.Unlock()
+	}
+	cfg.OnConnClose = func(stats ConnStats) {
+		s.mu.Lock()
+		s.stats = append(s.stats, stats)
+		s.mu.Unlock()
+		s.closed <- stats.ID
+	}
+	srv := New(cfg)
+	s.Require().NoError(srv.Listen())
+	s.srv = srv
+	s.stopped = make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		defer close(s.stopped)
+		done <- srv.Serve(context.Background())
+	}()
+	return srv, done
+}
+
+// dial connects to the server.
+func (s *StreamSuite) dial() net.Conn {
+	conn, err := net.Dial("unix", s.path)
+	s.Require().NoError(err)
+	return conn
+}
+
+// waitClosed waits for n connections to be closed.
+func (s *StreamSuite) waitClosed(n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-s.closed:
+		case <-time.After(5 * time.Second):
+			s.FailNow("connection was not closed")
+		}
+	}
+}
+
+func (s *StreamSuite) Test_Stream() {
+	rec := newRecorder()
+	srv, done := s.start(rec, 0)
+
+	fi, err := os.Stat(s.path)
+	s.Require().NoError(err)
+	s.Equal(DefaultSocketMode, fi.Mode().Perm())
+
+	conn := s.dial()
+	_, err = conn.Write(append(frame("foo:1|c\nbar:2|g"), frame("foo:1|x")...))
+	s.Require().NoError(err)
+	s.Require().True(rec.wait(3))
+	s.Require().NoError(conn.Close())
+	s.waitClosed(1)
+
+	s.mu.Lock()
+	s.Require().Len(s.stats, 1)
+	stats := s.stats[0]
+	s.mu.Unlock()
+	s.Equal(uint64(1), stats.ID)
+	s.Equal(2, stats.Frames)
+	s.Equal(len("foo:1|c\nbar:2|g")+len("foo:1|x"), stats.Bytes)
+	s.Equal(2, stats.Metrics)
+	s.Equal(1, stats.ParseErrors)
+	s.Zero(stats.Dropped)
+	s.NotZero(stats.Duration)
+
+	s.NoError(srv.Close())
+	s.True(errors.Is(<-done, ErrServerClosed))
+	_, err = os.Stat(s.path)
+	s.True(os.IsNotExist(err), "socket file should be removed on close")
+}
+
+func (s *StreamSuite) Test_Stream_Concurrent() {
+	rec := newRecorder()
+	s.start(rec, 0)
+
+	const conns = 10
+	const frames = 20
+	var wg sync.WaitGroup
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.Dial("unix", s.path)
+			if !s.NoError(err) {
+				return
+			}
+			defer conn.Close()
+			for j := 0; j < frames; j++ {
+				_, err := conn.Write(frame("foo:1|c"))
+				s.NoError(err)
+			}
+		}()
+	}
+	wg.Wait()
+	s.Require().True(rec.wait(conns * frames))
+	s.waitClosed(conns)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []int
+	for _, stats := range s.stats {
+		s.Equal(frames, stats.Frames)
+		ids = append(ids, int(stats.ID))
+	}
+	sort.Ints(ids)
+	s.Equal([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, ids)
+}
+
+func (s *StreamSuite) Test_Stream_PartialWrites() {
+	rec := newRecorder()
+	s.start(rec, 0)
+
+	conn := s.dial()
+	defer conn.Close()
+	b := frame("foo:1|c|#env:dev")
+	for _, part := range [][]byte{b[:2], b[2:6], b[6:10], b[10:]} {
+		_, err := conn.Write(part)
+		s.Require().NoError(err)
+		time.Sleep(5 * time.Millisecond)
+	}
+	s.Require().True(rec.wait(1))
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	s.Require().Len(rec.metrics, 1)
+	s.Equal([]string{"env:dev"}, rec.metrics[0].Tags)
+}
+
+func (s *StreamSuite) Test_Stream_FrameTooLarge() {
+	rec := newRecorder()
+	s.start(rec, 16)
+
+	conn := s.dial()
+	payload := append(frame("much.too.long.for.the.buffer:1|c"), frame("foo:1|c")...)
+	_, err := conn.Write(payload)
+	s.Require().NoError(err)
+	// the connection carries on after dropping the frame
+	s.Require().True(rec.wait(1))
+	s.Require().NoError(conn.Close())
+	s.waitClosed(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Require().Len(s.errs, 1)
+	s.True(errors.Is(s.errs[0], ErrFrameTooLarge))
+	s.Equal(1, s.stats[0].Frames)
+	s.Equal(1, s.stats[0].Dropped)
+}
+
+func (s *StreamSuite) Test_Stream_Truncated() {
+	rec := newRecorder()
+	s.start(rec, 0)
+
+	conn := s.dial()
+	_, err := conn.Write(frame("foo:1|c")[:6])
+	s.Require().NoError(err)
+	s.Require().NoError(conn.Close())
+	s.waitClosed(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Require().Len(s.errs, 1)
+	s.True(errors.Is(s.errs[0], ErrTruncatedFrame))
+	s.Equal(0, s.stats[0].Frames)
+	s.Equal(1, s.stats[0].Dropped)
+}
+
+func (s *StreamSuite) Test_Stream_CloseOpenConns() {
+	rec := newRecorder()
+	srv, done := s.start(rec, 0)
+	conn := s.dial()
+	defer conn.Close()
+	// make sure the connection has been accepted
+	_, err := conn.Write(frame("foo:1|c"))
+	s.Require().NoError(err)
+	s.Require().True(rec.wait(1))
+
+	s.NoError(srv.Close())
+	select {
+	case err := <-done:
+		s.True(errors.Is(err, ErrServerClosed))
+	case <-time.After(5 * time.Second):
+		s.Fail("server did not stop")
+	}
+	s.waitClosed(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Empty(s.errs)
+}
+
+func (s *StreamSuite) Test_readLengthPrefixed() {
+	buf := make([]byte, 8)
+	payload := append(frame("foo:1|c"), frame("")...)
+	payload = append(payload, frame("too:long|c")...)
+	payload = append(payload, frame("bar:2|g")...)
+	payload = append(payload, frame("foo")[:5]...)
+
+	// byte at a time reads must be reassembled
+	r := bufio.NewReader(iotest.OneByteReader(bytes.NewReader(payload)))
+	b, err := readLengthPrefixed(r, buf)
+	s.Require().NoError(err)
+	s.Equal("foo:1|c", string(b))
+	b, err = readLengthPrefixed(r, buf)
+	s.Require().NoError(err)
+	s.Empty(b)
+	_, err = readLengthPrefixed(r, buf)
+	s.True(errors.Is(err, ErrFrameTooLarge))
+	b, err = readLengthPrefixed(r, buf)
+	s.Require().NoError(err)
+	s.Equal("bar:2|g", string(b))
+	_, err = readLengthPrefixed(r, buf)
+	s.True(errors.Is(err, ErrTruncatedFrame))
+
+	_, err = readLengthPrefixed(bufio.NewReader(bytes.NewReader(nil)), buf)
+	s.Equal(io.EOF, err)
+	_, err = readLengthPrefixed(bufio.NewReader(bytes.NewReader([]byte{1, 0})), buf)
+	s.True(errors.Is(err, ErrTruncatedFrame))
+	_, err = readLengthPrefixed(bufio.NewReader(bytes.NewReader(frame("much.too.long")[:6])), buf)
+	s.True(errors.Is(err, ErrTruncatedFrame))
+}
+
+func TestStreamSuite(t *testing.T) {
+	suite.Run(t, new(StreamSuite))
+}
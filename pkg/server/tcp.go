@@ -0,0 +1,51 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// ErrUnterminatedLine is reported to OnError when data left after the last newline of a TCP connection is dropped
+// because EOLRequired is set.
+var ErrUnterminatedLine = fmt.Errorf("unterminated line")
+
+// readLines returns a frameReader which reads newline-terminated lines, as sent over TCP.
+// Data after the last newline of a connection is read as a final line, or dropped if eolRequired is true.
+func readLines(eolRequired bool) frameReader {
+	return func(r *bufio.Reader, buf []byte) ([]byte, error) {
+		line := buf[:0]
+		n := 0
+		for {
+			chunk, err := r.ReadSlice('\n')
+			if err == nil {
+				chunk = chunk[:len(chunk)-1]
+			}
+			n += len(chunk)
+			if n <= len(buf) {
+				line = append(line, chunk...)
+			}
+
+			switch {
+			case err == bufio.ErrBufferFull:
+				continue
+			case err == nil && n > len(buf):
+				return nil, fmt.Errorf("%w: line of %d bytes exceeds buffer size of %d", ErrFrameTooLarge, n, len(buf))
+			case err == nil:
+				return line, nil
+			case err == io.EOF && n == 0:
+				return nil, io.EOF
+			case err == io.EOF && eolRequired:
+				return nil, fmt.Errorf("%w: dropped %d bytes", ErrUnterminatedLine, n)
+			case err == io.EOF && n > len(buf):
+				return nil, fmt.Errorf("%w: line of %d bytes exceeds buffer size of %d", ErrTruncatedFrame, n, len(buf))
+			case err == io.EOF:
+				return line, nil
+			}
+			if n > 0 {
+				return nil, fmt.Errorf("%w: %d bytes: %w", ErrTruncatedFrame, n, err)
+			}
+			return nil, err
+		}
+	}
+}
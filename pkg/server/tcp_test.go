@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing/iotest"
+	"time"
+)
+
+// startTCP starts a server on a free TCP port.
+func (s *StreamSuite) startTCP(rec *recorder, cfg Config) *Server {
+	cfg.Network = "tcp"
+	cfg.Addr = "127.0.0.1:0"
+	cfg.Handler = rec
+	srv, _ := s.startConfig(cfg)
+	return srv
+}
+
+// dialTCP connects to srv.
+func (s *StreamSuite) dialTCP(srv *Server) net.Conn {
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	s.Require().NoError(err)
+	return conn
+}
+
+func (s *StreamSuite) Test_TCP() {
+	rec := newRecorder()
+	srv := s.startTCP(rec, Config{})
+
+	conn := s.dialTCP(srv)
+	for _, part := range []string{"foo:1|c|#env:dev\nba", "r:2|g\n", "foo:1|x\nbaz:3|c"} {
+		_, err := conn.Write([]byte(part))
+		s.Require().NoError(err)
+		time.Sleep(5 * time.Millisecond)
+	}
+	s.Require().NoError(conn.Close())
+	s.Require().True(rec.wait(4))
+	s.waitClosed(1)
+
+	rec.mu.Lock()
+	s.Require().Len(rec.metrics, 3)
+	s.Equal("bar", rec.metrics[1].Name)
+	// the unterminated final line is parsed
+	s.Equal("baz", rec.metrics[2].Name)
+	s.Require().Len(rec.errs, 1)
+	rec.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Empty(s.errs)
+	s.Equal(4, s.stats[0].Frames)
+	s.Equal(3, s.stats[0].Metrics)
+	s.Equal(1, s.stats[0].ParseErrors)
+	s.NotEmpty(s.stats[0].Remote)
+}
+
+func (s *StreamSuite) Test_TCP_EOLRequired() {
+	rec := newRecorder()
+	srv := s.startTCP(rec, Config{EOLRequired: true})
+
+	conn := s.dialTCP(srv)
+	_, err := conn.Write([]byte("foo:1|c\nbar:2|g"))
+	s.Require().NoError(err)
+	s.Require().NoError(conn.Close())
+	s.waitClosed(1)
+
+	rec.mu.Lock()
+	s.Require().Len(rec.metrics, 1)
+	s.Equal("foo", rec.metrics[0].Name)
+	rec.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Require().Len(s.errs, 1)
+	s.True(errors.Is(s.errs[0], ErrUnterminatedLine))
+	s.Equal(1, s.stats[0].Dropped)
+}
+
+func (s *StreamSuite) Test_TCP_LineTooLarge() {
+	rec := newRecorder()
+	srv := s.startTCP(rec, Config{BufferSize: 16})
+
+	conn := s.dialTCP(srv)
+	_, err := conn.Write([]byte(strings.Repeat("x", 5000) + ":1|c\nfoo:1|c\n"))
+	s.Require().NoError(err)
+	// the connection carries on after dropping the line
+	s.Require().True(rec.wait(1))
+	s.Require().NoError(conn.Close())
+	s.waitClosed(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Require().Len(s.errs, 1)
+	s.True(errors.Is(s.errs[0], ErrFrameTooLarge))
+	s.Equal(1, s.stats[0].Frames)
+	s.Equal(1, s.stats[0].Dropped)
+}
+
+func (s *StreamSuite) Test_TCP_IdleTimeout() {
+	rec := newRecorder()
+	srv := s.startTCP(rec, Config{IdleTimeout: 50 * time.Millisecond})
+
+	conn := s.dialTCP(srv)
+	defer conn.Close()
+	_, err := conn.Write([]byte("foo:1|c\n"))
+	s.Require().NoError(err)
+	s.waitClosed(1)
+
+	// the server has closed the connection
+	s.Require().NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	s.Equal(io.EOF, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Empty(s.errs)
+	s.True(s.stats[0].TimedOut)
+	s.Equal(1, s.stats[0].Frames)
+}
+
+func (s *StreamSuite) Test_TCP_MaxConns() {
+	rec := newRecorder()
+	srv := s.startTCP(rec, Config{MaxConns: 1})
+
+	first := s.dialTCP(srv)
+	defer first.Close()
+	_, err := first.Write([]byte("foo:1|c\n"))
+	s.Require().NoError(err)
+	s.Require().True(rec.wait(1))
+
+	second := s.dialTCP(srv)
+	defer second.Close()
+	s.Require().NoError(second.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	_, err = second.Read(make([]byte, 1))
+	s.Error(err, "second connection should be closed")
+
+	s.mu.Lock()
+	s.Require().Len(s.errs, 1)
+	s.True(errors.Is(s.errs[0], ErrTooManyConns))
+	s.mu.Unlock()
+
+	// a connection can be made once the first is closed
+	s.Require().NoError(first.Close())
+	s.waitClosed(1)
+	third := s.dialTCP(srv)
+	defer third.Close()
+	_, err = third.Write([]byte("bar:1|c\n"))
+	s.Require().NoError(err)
+	s.Require().True(rec.wait(1))
+}
+
+func (s *StreamSuite) Test_readLines() {
+	buf := make([]byte, 24)
+	payload := "foo:1|c\n\n" + strings.Repeat("x", 40) + "\nbar:2|g\nbaz"
+
+	for _, eolRequired := range []bool{false, true} {
+		// a small buffered reader splits long lines into several chunks
+		r := bufio.NewReaderSize(iotest.OneByteReader(strings.NewReader(payload)), 16)
+		next := readLines(eolRequired)
+		b, err := next(r, buf)
+		s.Require().NoError(err)
+		s.Equal("foo:1|c", string(b))
+		b, err = next(r, buf)
+		s.Require().NoError(err)
+		s.Empty(b)
+		_, err = next(r, buf)
+		s.True(errors.Is(err, ErrFrameTooLarge))
+		b, err = next(r, buf)
+		s.Require().NoError(err)
+		s.Equal("bar:2|g", string(b))
+
+		b, err = next(r, buf)
+		if eolRequired {
+			s.True(errors.Is(err, ErrUnterminatedLine))
+		} else {
+			s.Require().NoError(err)
+			s.Equal("baz", string(b))
+			_, err = next(r, buf)
+			s.Equal(io.EOF, err)
+		}
+	}
+
+	_, err := readLines(false)(bufio.NewReader(strings.NewReader(strings.Repeat("x", 40))), buf)
+	s.True(errors.Is(err, ErrTruncatedFrame))
+}
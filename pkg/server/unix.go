@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// DefaultSocketMode is the permissions of a unix socket created by a Server, allowing any user to write to it as the agent does.
+const DefaultSocketMode os.FileMode = 0722
+
+// ErrSocketInUse is returned upon listening on a unix socket which another process is already listening on.
+var ErrSocketInUse = fmt.Errorf("socket already in use")
+
+// listenUnixgram listens on a unix datagram socket at path, removing any stale socket left at path by a previous process.
+func listenUnixgram(path string, mode os.FileMode) (net.PacketConn, error) {
+	if err := removeStaleSocket("unixgram", path); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		conn.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// listenUnix listens on a unix stream socket at path, removing any stale socket left at path by a previous process.
+// The socket file is removed when the listener is closed.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket("unix", path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket at path if no process is listening on it.
+// Returns an error if path exists but is not a socket, or if a process is listening on it.
+func removeStaleSocket(network, path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	// connecting to a socket nobody is listening on fails
+	if conn, err := net.Dial(network, path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s: %w", path, ErrSocketInUse)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UnixSuite struct {
+	suite.Suite
+	path string
+}
+
+func (s *UnixSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "dsd.socket")
+}
+
+func (s *UnixSuite) Test_Unixgram() {
+	rec := newRecorder()
+	srv := New(Config{Network: "unixgram", Addr: s.path, Handler: rec})
+	s.Require().NoError(srv.Listen())
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(context.Background())
+	}()
+
+	fi, err := os.Stat(s.path)
+	s.Require().NoError(err)
+	s.Equal(DefaultSocketMode, fi.Mode().Perm())
+	s.Equal(s.path, srv.Addr().String())
+
+	conn, err := net.Dial("unixgram", s.path)
+	s.Require().NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("foo:1|c\nbar:2|g"))
+	s.Require().NoError(err)
+	s.Require().True(rec.wait(2))
+	rec.mu.Lock()
+	s.Require().Len(rec.metrics, 2)
+	s.Equal("bar", rec.metrics[1].Name)
+	rec.mu.Unlock()
+
+	s.NoError(srv.Close())
+	s.True(errors.Is(<-done, ErrServerClosed))
+	_, err = os.Stat(s.path)
+	s.True(os.IsNotExist(err), "socket file should be removed on close")
+}
+
+func (s *UnixSuite) Test_Unixgram_SocketMode() {
+	srv := New(Config{Network: "unixgram", Addr: s.path, SocketMode: 0700})
+	s.Require().NoError(srv.Listen())
+	defer srv.Close()
+	fi, err := os.Stat(s.path)
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0700), fi.Mode().Perm())
+}
+
+func (s *UnixSuite) Test_Unixgram_StaleSocket() {
+	// a socket file left behind by a process which exited without cleaning up
+	stale, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: s.path, Net: "unixgram"})
+	s.Require().NoError(err)
+	s.Require().NoError(stale.Close())
+	_, err = os.Stat(s.path)
+	s.Require().NoError(err)
+
+	srv := New(Config{Network: "unixgram", Addr: s.path})
+	s.NoError(srv.Listen())
+	s.NoError(srv.Close())
+}
+
+func (s *UnixSuite) Test_Unixgram_InUse() {
+	srv := New(Config{Network: "unixgram", Addr: s.path})
+	s.Require().NoError(srv.Listen())
+	defer srv.Close()
+
+	other := New(Config{Network: "unixgram", Addr: s.path})
+	s.True(errors.Is(other.Listen(), ErrSocketInUse))
+	_, err := os.Stat(s.path)
+	s.NoError(err, "socket of the listening server should be kept")
+}
+
+func (s *UnixSuite) Test_Unixgram_NotSocket() {
+	s.Require().NoError(os.WriteFile(s.path, []byte("keep me"), 0600))
+	srv := New(Config{Network: "unixgram", Addr: s.path})
+	s.Error(srv.Listen())
+	b, err := os.ReadFile(s.path)
+	s.Require().NoError(err)
+	s.Equal("keep me", string(b))
+}
+
+func (s *UnixSuite) Test_UnsupportedNetwork() {
+	srv := New(Config{Network: "ipx"})
+	s.True(errors.Is(srv.Listen(), ErrUnsupportedNetwork))
+}
+
+func TestUnixSuite(t *testing.T) {
+	suite.Run(t, new(UnixSuite))
+}
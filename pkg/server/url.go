@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ErrInvalidListenURL is returned by ParseListenURL for URLs which do not name an address to listen on.
+var ErrInvalidListenURL = fmt.Errorf("invalid listen url")
+
+// ParseListenURL returns the Network and Addr of a Config listening on the address named by raw, e.g.
+//
+//	udp://localhost:8125, udp4://0.0.0.0:8125, udp6://[::1]:8125, tcp://localhost:8125,
+//	unixgram:///var/run/datadog/dsd.socket, unix:///var/run/datadog/dsd-stream.socket
+//
+// A udp or tcp URL with an unspecified host such as udp://[::]:8125 listens on both IPv4 and IPv6 where supported.
+// A relative socket path can be given as an opaque URL such as unixgram:dsd.socket.
+func ParseListenURL(raw string) (network, addr string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidListenURL, err)
+	}
+
+	switch u.Scheme {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+		if u.Port() == "" || (u.Path != "" && u.Path != "/") || u.Opaque != "" {
+			return "", "", fmt.Errorf("%w: %q must be host:port, e.g. %s://localhost:8125", ErrInvalidListenURL, raw, u.Scheme)
+		}
+		return u.Scheme, u.Host, nil
+	case "unixgram", "unix":
+		path := u.Path
+		if u.Opaque != "" {
+			path = u.Opaque
+		}
+		if path == "" || u.Host != "" {
+			return "", "", fmt.Errorf("%w: %q must be a socket path, e.g. %s:///tmp/dsd.socket", ErrInvalidListenURL, raw, u.Scheme)
+		}
+		return u.Scheme, path, nil
+	}
+	return "", "", fmt.Errorf("%w: %q", ErrUnsupportedNetwork, u.Scheme)
+}
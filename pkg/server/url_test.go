@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ListenURLSuite struct {
+	suite.Suite
+}
+
+func (s *ListenURLSuite) Test_ParseListenURL() {
+	for _, tc := range []struct {
+		raw     string
+		network string
+		addr    string
+	}{
+		{"udp://localhost:8125", "udp", "localhost:8125"},
+		{"udp://0.0.0.0:8125", "udp", "0.0.0.0:8125"},
+		{"udp://:8125", "udp", ":8125"},
+		{"udp4://127.0.0.1:0", "udp4", "127.0.0.1:0"},
+		{"udp6://[::1]:8125", "udp6", "[::1]:8125"},
+		{"udp://[::]:8125/", "udp", "[::]:8125"},
+		{"tcp://localhost:8125", "tcp", "localhost:8125"},
+		{"tcp6://[::1]:8125", "tcp6", "[::1]:8125"},
+		{"unixgram:///tmp/dsd.sock", "unixgram", "/tmp/dsd.sock"},
+		{"unix:///var/run/datadog/dsd.socket", "unix", "/var/run/datadog/dsd.socket"},
+		{"unixgram:dsd.sock", "unixgram", "dsd.sock"},
+	} {
+		network, addr, err := ParseListenURL(tc.raw)
+		s.NoError(err, tc.raw)
+		s.Equal(tc.network, network, tc.raw)
+		s.Equal(tc.addr, addr, tc.raw)
+	}
+}
+
+func (s *ListenURLSuite) Test_ParseListenURL_Invalid() {
+	for _, raw := range []string{
+		"udp://localhost",
+		"udp://localhost:8125/path",
+		"udp:localhost",
+		"unixgram://tmp/dsd.sock",
+		"unix://",
+		"%",
+	} {
+		_, _, err := ParseListenURL(raw)
+		s.True(errors.Is(err, ErrInvalidListenURL), raw)
+	}
+
+	for _, raw := range []string{"localhost:8125", "http://localhost:8125", "sctp://localhost:8125", "/tmp/dsd.sock"} {
+		_, _, err := ParseListenURL(raw)
+		s.True(errors.Is(err, ErrUnsupportedNetwork), raw)
+	}
+}
+
+func (s *ListenURLSuite) Test_ParseListenURL_IPv6() {
+	network, addr, err := ParseListenURL("udp6://[::1]:0")
+	s.Require().NoError(err)
+	rec := newRecorder()
+	srv := New(Config{Network: network, Addr: addr, Handler: rec})
+	if err := srv.Listen(); err != nil {
+		s.T().Skip("IPv6 is not available: ", err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Close()
+
+	conn, err := net.Dial("udp6", srv.Addr().String())
+	s.Require().NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("foo:1|c"))
+	s.Require().NoError(err)
+	s.True(rec.wait(1))
+}
+
+func TestListenURLSuite(t *testing.T) {
+	suite.Run(t, new(ListenURLSuite))
+}